@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// aggregateFuncs are the aggregate functions query_select accepts, keyed by
+// the name used in the input.
+var aggregateFuncs = map[string]string{
+	"count":          "COUNT",
+	"count_distinct": "COUNT",
+	"sum":            "SUM",
+	"avg":            "AVG",
+	"min":            "MIN",
+	"max":            "MAX",
+}
+
+// aggregate is a rendered aggregate: its expression, e.g. SUM("total"), and
+// the name of the result column.
+type aggregate struct {
+	expr  string
+	alias string
+}
+
+// column returns the select list entry for the aggregate.
+func (a aggregate) column() string {
+	return a.expr + " AS " + dialect.QuoteIdentifier(a.alias)
+}
+
+// buildAggregates renders aggs, validating their columns against scope when
+// the query has joins. Aliases default to the function and column name, such
+// as sum_total, and must be unique.
+func buildAggregates(aggs []Aggregate, scope *joinScope) ([]aggregate, error) {
+	result := make([]aggregate, 0, len(aggs))
+	seen := map[string]bool{}
+	for _, agg := range aggs {
+		name := strings.ToLower(strings.TrimSpace(agg.Func))
+		fn, ok := aggregateFuncs[name]
+		if !ok {
+			return nil, fmt.Errorf("invalid aggregate function '%s' (expected count, count_distinct, sum, avg, min or max)", agg.Func)
+		}
+
+		column := strings.TrimSpace(agg.Column)
+		var arg string
+		switch {
+		case column == "" || column == "*":
+			if name != "count" {
+				return nil, fmt.Errorf("aggregate %s requires a column", name)
+			}
+			column, arg = "", "*"
+		case strings.HasSuffix(column, ".*"):
+			return nil, fmt.Errorf("invalid aggregate column '%s'", column)
+		default:
+			if scope != nil {
+				if err := scope.check(column); err != nil {
+					return nil, fmt.Errorf("invalid aggregate column: %w", err)
+				}
+			}
+			quoted, err := quoteIdentifier(column)
+			if err != nil {
+				return nil, fmt.Errorf("invalid aggregate column: %w", err)
+			}
+			arg = quoted
+			if name == "count_distinct" {
+				arg = "DISTINCT " + quoted
+			}
+		}
+
+		alias := strings.TrimSpace(agg.Alias)
+		if alias == "" {
+			alias = name
+			if column != "" {
+				alias += "_" + strings.ReplaceAll(column, ".", "_")
+			}
+		}
+		if strings.Contains(alias, ".") {
+			return nil, fmt.Errorf("invalid aggregate alias %q: must not contain '.'", alias)
+		}
+		if _, err := quoteIdentifier(alias); err != nil {
+			return nil, fmt.Errorf("invalid aggregate alias: %w", err)
+		}
+		if seen[alias] {
+			return nil, fmt.Errorf("aggregate alias '%s' is used twice", alias)
+		}
+		seen[alias] = true
+
+		result = append(result, aggregate{expr: fn + "(" + arg + ")", alias: alias})
+	}
+	return result, nil
+}
+
+// findAggregate returns the aggregate whose alias is name.
+func findAggregate(aggs []aggregate, name string) (aggregate, bool) {
+	for _, agg := range aggs {
+		if agg.alias == strings.TrimSpace(name) {
+			return agg, true
+		}
+	}
+	return aggregate{}, false
+}
+
+// applyHavingConditions adds HAVING conditions. A condition's column is
+// either an aggregate alias, compared through the aggregate expression since
+// PostgreSQL does not allow aliases in HAVING, or a grouped column.
+func applyHavingConditions(query sq.SelectBuilder, clauses []WhereClause, aggs []aggregate, scope *joinScope) (sq.SelectBuilder, error) {
+	filter, err := buildFilter(clauses, func(column string) (string, error) {
+		if agg, ok := findAggregate(aggs, column); ok {
+			return agg.expr, nil
+		}
+		if scope != nil {
+			if err := scope.check(column); err != nil {
+				return "", fmt.Errorf("invalid HAVING column: %w", err)
+			}
+		}
+		col, err := quoteIdentifier(column)
+		if err != nil {
+			return "", fmt.Errorf("invalid HAVING column: %w", err)
+		}
+		return col, nil
+	})
+	if err != nil {
+		return query, err
+	}
+	return query.Having(filter), nil
+}
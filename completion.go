@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// maxCompletionValues is the most values returned by one completion request,
+// as allowed by the MCP specification.
+const maxCompletionValues = 100
+
+// metadataCache keeps catalog lookups (schemas, tables, columns) for
+// metadataCacheTTL so completion requests, which arrive on every keystroke,
+// do not each hit the database.
+type metadataCache struct {
+	mu      sync.Mutex
+	entries map[string]metadataCacheEntry
+}
+
+type metadataCacheEntry struct {
+	values  []string
+	expires time.Time
+}
+
+var completionCache = &metadataCache{entries: make(map[string]metadataCacheEntry)}
+
+// get returns the cached values for key, calling load and caching its result
+// when the entry is missing or expired.
+func (c *metadataCache) get(key string, load func() ([]string, error)) ([]string, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.values, nil
+	}
+
+	values, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.entries[key] = metadataCacheEntry{values: values, expires: time.Now().Add(metadataCacheTTL)}
+	c.mu.Unlock()
+	return values, nil
+}
+
+// CompleteArgument handles completion/complete for prompt and resource
+// template arguments. Arguments are completed by name: database from the
+// allowlist, schema, table and tables from the catalog, and column from the
+// table already chosen. Arguments resolved earlier in the same prompt or
+// template are used as context; database defaults to the primary database.
+func CompleteArgument(ctx context.Context, req *mcp.CompleteRequest) (*mcp.CompleteResult, error) {
+	args := map[string]string{}
+	if req.Params.Context != nil && req.Params.Context.Arguments != nil {
+		args = req.Params.Context.Arguments
+	}
+	database := args["database"]
+	if database == "" {
+		database = dbNames[0]
+	}
+
+	name, value := req.Params.Argument.Name, req.Params.Argument.Value
+	prefix := ""
+	var candidates []string
+	var err error
+
+	switch name {
+	case "database":
+		candidates = dbNames
+	case "schema":
+		candidates, err = completionCache.get("schemas|"+database, func() ([]string, error) {
+			pool, err := getPool(database)
+			if err != nil {
+				return nil, err
+			}
+			return dialect.ListSchemas(ctx, pool.db, database)
+		})
+	case "table", "tables":
+		// "tables" is a comma-separated list; complete its last entry
+		if i := strings.LastIndex(value, ","); name == "tables" && i >= 0 {
+			prefix = value[:i+1] + " "
+			value = strings.TrimSpace(value[i+1:])
+		}
+		candidates, err = cachedTables(ctx, database, args["schema"])
+	case "column":
+		candidates, err = cachedColumns(ctx, database, args["schema"], args["table"])
+	}
+	if err != nil {
+		// Completion is best effort; an unreachable database or unknown
+		// table simply yields no suggestions.
+		candidates = nil
+	}
+
+	values := []string{}
+	for _, candidate := range candidates {
+		if strings.HasPrefix(strings.ToLower(candidate), strings.ToLower(value)) {
+			values = append(values, prefix+candidate)
+		}
+	}
+
+	result := &mcp.CompleteResult{
+		Completion: mcp.CompletionResultDetails{Values: values, Total: len(values)},
+	}
+	if len(values) > maxCompletionValues {
+		result.Completion.Values = values[:maxCompletionValues]
+		result.Completion.HasMore = true
+	}
+	return result, nil
+}
+
+func cachedTables(ctx context.Context, database, schema string) ([]string, error) {
+	return completionCache.get("tables|"+database+"|"+schema, func() ([]string, error) {
+		pool, err := getPool(database)
+		if err != nil {
+			return nil, err
+		}
+		return dialect.ListTables(ctx, pool.db, database, schema)
+	})
+}
+
+func cachedColumns(ctx context.Context, database, schema, table string) ([]string, error) {
+	if table == "" {
+		return nil, nil
+	}
+	return completionCache.get("columns|"+database+"|"+schema+"|"+table, func() ([]string, error) {
+		pool, err := getPool(database)
+		if err != nil {
+			return nil, err
+		}
+		schema, err := dialect.TableSchema(ctx, pool.db, database, schema, table)
+		if err != nil {
+			return nil, err
+		}
+		columns := make([]string, 0, len(schema.Columns))
+		for _, column := range schema.Columns {
+			columns = append(columns, column.Name)
+		}
+		return columns, nil
+	})
+}
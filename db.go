@@ -1,39 +1,91 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	sq "github.com/Masterminds/squirrel"
 	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/lib/pq"
 )
 
-var db *sql.DB
+// dbPool is a connection pool bound to a single database from the allowlist,
+// together with a query builder that runs against it.
+type dbPool struct {
+	db *sql.DB
+	qb sq.StatementBuilderType
+}
+
 var dbType string
+var dialect Dialect
 var dbNames []string
 var readOnly bool
 var allowRawQuery bool
-var qb sq.StatementBuilderType
+var allowMultiStatements bool
 var maxSelectLimit int
 var maxUpdateLimit int
 var maxDeleteLimit int
+var maxInsertRows int
+var metadataCacheTTL time.Duration
+var queryTimeout time.Duration
+var maxQueryTimeout time.Duration
+var maxQueryCost dbThreshold
+var maxRowsScanned dbThreshold
+
+var dbHost string
+var dbPort string
+var dbUser string
+var dbPassword string
+
+// txBeginner is implemented by both *sql.DB and *sql.Conn.
+type txBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// queryer is implemented by *sql.DB, *sql.Conn and *sql.Tx, so the same statement
+// can run on its own or as part of a transaction.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+var pools = make(map[string]*dbPool)
+var poolsMu sync.Mutex
 
 func initDatabase() error {
 	dbType = getEnv("DB_TYPE", "postgres")
-	host := getEnv("DB_HOST", "localhost")
-	port := getEnv("DB_PORT", "5432")
-	user := getEnv("DB_USER", "postgres")
-	password := getEnv("DB_PASSWORD", "")
+	dbHost = getEnv("DB_HOST", "localhost")
+	dbPort = getEnv("DB_PORT", "")
+	dbUser = getEnv("DB_USER", "postgres")
+	dbPassword = getEnv("DB_PASSWORD", "")
 	dbNamesStr := getEnv("DB_NAME", "postgres")
 	readOnly = getEnv("DB_READONLY", "false") == "true"
+
+	// In read-only mode, optionally connect as a user that can only read
+	if user := getEnv("DB_READONLY_USER", ""); readOnly && user != "" {
+		dbUser = user
+		dbPassword = getEnv("DB_READONLY_PASSWORD", "")
+	}
 	allowRawQuery = getEnv("ALLOW_RAW_QUERY", "false") == "true"
+	allowMultiStatements = getEnv("ALLOW_MULTI_STATEMENTS", "false") == "true"
 	maxSelectLimit = getEnvInt("MAX_SELECT_LIMIT", 1000)
 	maxUpdateLimit = getEnvInt("MAX_UPDATE_LIMIT", 1)
 	maxDeleteLimit = getEnvInt("MAX_DELETE_LIMIT", 1)
+	maxInsertRows = getEnvInt("MAX_INSERT_ROWS", 1000)
+	metadataCacheTTL = time.Duration(getEnvInt("METADATA_CACHE_TTL", 30)) * time.Second
+	queryTimeout = time.Duration(getEnvInt("QUERY_TIMEOUT_MS", 30000)) * time.Millisecond
+	maxQueryTimeout = time.Duration(getEnvInt("MAX_QUERY_TIMEOUT_MS", 300000)) * time.Millisecond
+	maxQueryCost = getEnvThreshold("MAX_QUERY_COST")
+	maxRowsScanned = getEnvThreshold("MAX_ROWS_SCANNED")
 
 	// Parse comma-separated database names
 	dbNames = strings.Split(dbNamesStr, ",")
@@ -41,54 +93,184 @@ func initDatabase() error {
 		dbNames[i] = strings.TrimSpace(name)
 	}
 
-	// Use first database for connection
+	var err error
+	if dialect, err = newDialect(dbType); err != nil {
+		return err
+	}
+	if dbType == "sqlite" && (maxQueryCost.enabled() || maxRowsScanned.enabled()) {
+		log.Printf("Warning: SQLite reports no cost or row estimates, ignoring MAX_QUERY_COST and MAX_ROWS_SCANNED")
+		maxQueryCost, maxRowsScanned = dbThreshold{}, dbThreshold{}
+	}
+
+	// Connect to the first database eagerly so configuration errors surface at
+	// startup; the remaining pools are opened on first use.
 	primaryDB := dbNames[0]
+	if _, err := getPool(primaryDB); err != nil {
+		return err
+	}
 
-	var connStr string
-	var err error
+	log.Printf("Connected to %s database(s): %v", dbType, dbNames)
+	log.Printf("Primary database: %s", primaryDB)
+	log.Printf("Read-only mode: %v", readOnly)
+	if readOnly && dbType != "sqlite" {
+		log.Printf("Read-only connections use database user: %s", dbUser)
+	}
+	log.Printf("Raw queries allowed: %v (multiple statements: %v)", allowRawQuery, allowMultiStatements)
+	log.Printf("Query limits - SELECT: %d, INSERT: %d, UPDATE: %d, DELETE: %d", maxSelectLimit, maxInsertRows, maxUpdateLimit, maxDeleteLimit)
+	log.Printf("Query timeout: %v (max %v)", queryTimeout, maxQueryTimeout)
+	log.Printf("Cost guard - estimated cost: %s, rows scanned: %s", maxQueryCost, maxRowsScanned)
+	return nil
+}
 
-	if dbType == "postgres" {
-		if port == "" {
-			port = "5432"
-		}
-		connStr = fmt.Sprintf(
-			"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-			host, port, user, password, primaryDB,
-		)
-		db, err = sql.Open("postgres", connStr)
-		// Use PostgreSQL placeholder format ($1, $2, etc.)
-		qb = sq.StatementBuilder.PlaceholderFormat(sq.Dollar).RunWith(db)
-	} else if dbType == "mysql" {
-		if port == "" {
-			port = "3306"
-		}
-		connStr = fmt.Sprintf(
-			"%s:%s@tcp(%s:%s)/%s?parseTime=true",
-			user, password, host, port, primaryDB,
-		)
-		db, err = sql.Open("mysql", connStr)
-		// Use MySQL placeholder format (?)
-		qb = sq.StatementBuilder.PlaceholderFormat(sq.Question).RunWith(db)
-	} else {
-		return fmt.Errorf("unsupported database type: %s", dbType)
+// getPool returns the connection pool for database, opening it on first use.
+// The database must be in the configured allowlist.
+func getPool(database string) (*dbPool, error) {
+	if err := validateDatabase(database); err != nil {
+		return nil, err
 	}
 
+	poolsMu.Lock()
+	pool, ok := pools[database]
+	poolsMu.Unlock()
+	if ok {
+		return pool, nil
+	}
+
+	// Open outside the lock, so that an unreachable database does not
+	// block lookups of the others while its connection times out
+	pool, err := openPool(database)
 	if err != nil {
-		return fmt.Errorf("failed to open database: %w", err)
+		return nil, err
 	}
 
-	if err := db.Ping(); err != nil {
-		return fmt.Errorf("failed to connect to database: %w", err)
+	poolsMu.Lock()
+	defer poolsMu.Unlock()
+	if existing, ok := pools[database]; ok {
+		// Opened concurrently by another caller
+		pool.db.Close()
+		return existing, nil
 	}
+	pools[database] = pool
+	return pool, nil
+}
 
-	log.Printf("Connected to %s database(s): %v", dbType, dbNames)
-	log.Printf("Primary database: %s", primaryDB)
-	log.Printf("Read-only mode: %v", readOnly)
-	log.Printf("Raw queries allowed: %v", allowRawQuery)
-	log.Printf("Query limits - SELECT: %d, UPDATE: %d, DELETE: %d", maxSelectLimit, maxUpdateLimit, maxDeleteLimit)
+func openPool(database string) (*dbPool, error) {
+	conn, err := sql.Open(dialect.DriverName(), dialect.DSN(database))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database %s: %w", database, err)
+	}
+	conn.SetMaxOpenConns(dialect.MaxOpenConns())
+
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to connect to database %s: %w", database, err)
+	}
+
+	return &dbPool{
+		db: conn,
+		qb: sq.StatementBuilder.PlaceholderFormat(dialect.PlaceholderFormat()).RunWith(conn),
+	}, nil
+}
+
+// withTx runs fn in a transaction on db, committing if fn succeeds and
+// rolling back otherwise. In read-only mode the transaction is started READ
+// ONLY, so the database rejects any write made in it.
+func withTx(ctx context.Context, db txBeginner, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: readOnly})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
 	return nil
 }
 
+// timeoutFor returns the timeout of a query that asked for timeoutMS
+// milliseconds: QUERY_TIMEOUT_MS when unset, capped at MAX_QUERY_TIMEOUT_MS.
+// Zero means no timeout.
+func timeoutFor(timeoutMS int) time.Duration {
+	if timeoutMS <= 0 {
+		return queryTimeout
+	}
+	timeout := time.Duration(timeoutMS) * time.Millisecond
+	if maxQueryTimeout > 0 && timeout > maxQueryTimeout {
+		return maxQueryTimeout
+	}
+	return timeout
+}
+
+// withConn runs fn on a dedicated connection of db, bounded by timeout both
+// through the context and the server's own statement timeout. The query is
+// also stopped on the server when ctx is cancelled, e.g. by the client.
+func withConn(ctx context.Context, db *sql.DB, timeout time.Duration, fn func(ctx context.Context, conn *sql.Conn) error) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	cleanup, err := dialect.PrepareConn(ctx, db, conn, timeout)
+	if err != nil {
+		return fmt.Errorf("failed to prepare connection: %w", err)
+	}
+	err = fn(ctx, conn)
+	cleanup()
+
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("query timed out after %v: %w", timeout, err)
+	}
+	return err
+}
+
+// withSession runs fn like withConn. In read-only mode fn runs in a READ
+// ONLY transaction, so that writes hidden in raw SQL, functions or triggers
+// are rejected by the database and not only by the tool handlers.
+func withSession(ctx context.Context, db *sql.DB, timeout time.Duration, fn func(ctx context.Context, q queryer) error) error {
+	return withConn(ctx, db, timeout, func(ctx context.Context, conn *sql.Conn) error {
+		if !readOnly {
+			return fn(ctx, conn)
+		}
+		return withTx(ctx, conn, func(tx *sql.Tx) error {
+			return fn(ctx, tx)
+		})
+	})
+}
+
+// withConnTx runs fn in a transaction on a dedicated connection of db,
+// bounded by timeout like withConn.
+func withConnTx(ctx context.Context, db *sql.DB, timeout time.Duration, fn func(ctx context.Context, tx *sql.Tx) error) error {
+	return withConn(ctx, db, timeout, func(ctx context.Context, conn *sql.Conn) error {
+		return withTx(ctx, conn, func(tx *sql.Tx) error {
+			return fn(ctx, tx)
+		})
+	})
+}
+
+func closePools() {
+	poolsMu.Lock()
+	defer poolsMu.Unlock()
+
+	for name, pool := range pools {
+		if err := pool.db.Close(); err != nil {
+			log.Printf("Warning: failed to close database %s: %v", name, err)
+		}
+		delete(pools, name)
+	}
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -107,6 +289,72 @@ func getEnvInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+// dbThreshold is a limit that can differ per database. It is configured as
+// a default optionally followed by database=value overrides, e.g.
+// "100000,analytics=5000000"; 0 disables the limit.
+type dbThreshold struct {
+	fallback    float64
+	perDatabase map[string]float64
+}
+
+// of returns the limit for database, 0 when there is none.
+func (t dbThreshold) of(database string) float64 {
+	if value, ok := t.perDatabase[database]; ok {
+		return value
+	}
+	return t.fallback
+}
+
+func (t dbThreshold) enabled() bool {
+	return t.fallback > 0 || len(t.perDatabase) > 0
+}
+
+func (t dbThreshold) String() string {
+	if !t.enabled() {
+		return "off"
+	}
+	parts := []string{"off"}
+	if t.fallback > 0 {
+		parts[0] = fmt.Sprintf("%g", t.fallback)
+	}
+	for _, name := range dbNames {
+		if value, ok := t.perDatabase[name]; ok {
+			parts = append(parts, fmt.Sprintf("%s=%g", name, value))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func getEnvThreshold(key string) dbThreshold {
+	var threshold dbThreshold
+	for _, entry := range strings.Split(os.Getenv(key), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, value := "", entry
+		if i := strings.LastIndex(entry, "="); i >= 0 {
+			name, value = strings.TrimSpace(entry[:i]), strings.TrimSpace(entry[i+1:])
+		}
+		limit, err := strconv.ParseFloat(value, 64)
+		if err != nil || limit < 0 {
+			log.Printf("Warning: Invalid value for %s: %s, ignoring it", key, entry)
+			continue
+		}
+
+		if name == "" {
+			threshold.fallback = limit
+			continue
+		}
+		if threshold.perDatabase == nil {
+			threshold.perDatabase = make(map[string]float64)
+		}
+		threshold.perDatabase[name] = limit
+	}
+	return threshold
+}
+
 func validateDatabase(database string) error {
 	// Check if the database is in the allowed list
 	for _, allowedDB := range dbNames {
@@ -116,4 +364,3 @@ func validateDatabase(database string) error {
 	}
 	return fmt.Errorf("access to database '%s' not allowed (allowed: %v)", database, dbNames)
 }
-
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// Dialect encapsulates everything that differs between database engines:
+// connection strings, identifier quoting, placeholders and the catalog
+// queries behind the metadata and function tools. Supporting a new engine
+// means implementing this interface and registering it in newDialect.
+type Dialect interface {
+	// Name returns the DB_TYPE value that selects this dialect.
+	Name() string
+	// DriverName returns the database/sql driver name.
+	DriverName() string
+	// DSN returns the connection string for the given database.
+	DSN(database string) string
+	// MaxOpenConns limits the size of each connection pool; 0 means unlimited.
+	MaxOpenConns() int
+	// PlaceholderFormat returns the squirrel placeholder format for bound args.
+	PlaceholderFormat() sq.PlaceholderFormat
+	// QuoteIdentifier quotes a single identifier, escaping embedded quotes.
+	QuoteIdentifier(name string) string
+	// PrepareConn readies a dedicated connection for a query bounded by
+	// timeout (none when 0): it sets the server-side statement timeout and
+	// makes sure the server stops the query once ctx is done. The returned
+	// function undoes the setup before the connection is reused.
+	PrepareConn(ctx context.Context, db *sql.DB, conn *sql.Conn, timeout time.Duration) (func(), error)
+	// QualifiedTable returns the quoted table reference used by the query tools.
+	QualifiedTable(ctx context.Context, db *sql.DB, database, schema, table string) (string, error)
+	// Explain returns the plan of query and its summary, running the query
+	// as well when analyze is set. q is a transaction that is rolled back.
+	Explain(ctx context.Context, q queryer, query string, args []interface{}, analyze bool) (*ExplainOutput, error)
+	// ILike returns a case-insensitive LIKE of the quoted column (or
+	// expression) col against the pattern value.
+	ILike(col string, value interface{}) sq.Sqlizer
+	// SupportsReturning reports whether INSERT, UPDATE and DELETE accept a
+	// RETURNING clause.
+	SupportsReturning() bool
+	// UpsertClause returns the clause appended to an INSERT so that rows
+	// conflicting on the target columns overwrite the update columns instead.
+	// Both lists are quoted; an empty update list leaves conflicting rows as
+	// they are.
+	UpsertClause(target, update []string) string
+
+	// ListSchemas returns the schemas a table can live in; empty when the
+	// engine has no schemas below the database level.
+	ListSchemas(ctx context.Context, db *sql.DB, database string) ([]string, error)
+	ListTables(ctx context.Context, db *sql.DB, database, schema string) ([]string, error)
+	TableSchema(ctx context.Context, db *sql.DB, database, schema, table string) (*SchemaOutput, error)
+	Sequences(ctx context.Context, db *sql.DB, database, schema string) (*SequencesOutput, error)
+	CustomTypes(ctx context.Context, db *sql.DB, database, schema string) (*CustomTypesOutput, error)
+	Functions(ctx context.Context, db *sql.DB, database, schema string) (*FunctionsOutput, error)
+	FunctionSource(ctx context.Context, db *sql.DB, database, schema, name string) (*FunctionSourceOutput, error)
+
+	// RoutineKind reports whether name is a function or a stored procedure.
+	RoutineKind(ctx context.Context, db *sql.DB, database, schema, name string) (routineKind, error)
+	// RoutineCall returns the statement that invokes a routine with nparams
+	// bound parameters.
+	RoutineCall(database, schema, name string, nparams int, kind routineKind) string
+}
+
+type routineKind int
+
+const (
+	routineFunction routineKind = iota
+	routineProcedure
+)
+
+func newDialect(name string) (Dialect, error) {
+	switch name {
+	case "postgres":
+		return postgresDialect{}, nil
+	case "mysql":
+		return mysqlDialect{}, nil
+	case "sqlite":
+		return sqliteDialect{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported database type: %s", name)
+	}
+}
@@ -0,0 +1,482 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// mysqlDialect targets MySQL and MariaDB. MySQL has no schemas below the
+// database level, so the schema argument is ignored throughout.
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string       { return "mysql" }
+func (mysqlDialect) DriverName() string { return "mysql" }
+
+func (mysqlDialect) DSN(database string) string {
+	port := dbPort
+	if port == "" {
+		port = "3306"
+	}
+	return fmt.Sprintf(
+		"%s:%s@tcp(%s:%s)/%s?parseTime=true",
+		dbUser, dbPassword, dbHost, port, database,
+	)
+}
+
+func (mysqlDialect) MaxOpenConns() int {
+	return 0
+}
+
+// PlaceholderFormat uses MySQL placeholders (?)
+func (mysqlDialect) PlaceholderFormat() sq.PlaceholderFormat {
+	return sq.Question
+}
+
+func (mysqlDialect) QuoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// QualifiedTable qualifies the table with its database name.
+func (d mysqlDialect) QualifiedTable(ctx context.Context, db *sql.DB, database, schema, table string) (string, error) {
+	return d.QuoteIdentifier(database) + "." + d.QuoteIdentifier(table), nil
+}
+
+// PrepareConn sets MAX_EXECUTION_TIME (max_statement_time on MariaDB), which
+// MySQL applies to SELECT statements only. The driver merely closes the
+// connection when ctx is done, leaving the statement running on the server,
+// so the query is also killed from another connection. A connection that was
+// killed is discarded rather than returned to the pool, where a late KILL
+// could hit the next caller's query.
+func (mysqlDialect) PrepareConn(ctx context.Context, db *sql.DB, conn *sql.Conn, timeout time.Duration) (func(), error) {
+	reset := ""
+	if timeout > 0 {
+		if _, err := conn.ExecContext(ctx, fmt.Sprintf("SET SESSION MAX_EXECUTION_TIME = %d", timeout.Milliseconds())); err == nil {
+			reset = "SET SESSION MAX_EXECUTION_TIME = DEFAULT"
+		} else if _, mariaErr := conn.ExecContext(ctx, fmt.Sprintf("SET SESSION max_statement_time = %g", timeout.Seconds())); mariaErr == nil {
+			reset = "SET SESSION max_statement_time = DEFAULT"
+		} else {
+			return nil, err
+		}
+	}
+
+	var id int64
+	if err := conn.QueryRowContext(ctx, "SELECT CONNECTION_ID()").Scan(&id); err != nil {
+		return nil, err
+	}
+	killed := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		defer close(killed)
+		db.ExecContext(context.Background(), fmt.Sprintf("KILL QUERY %d", id))
+	})
+
+	return func() {
+		if !stop() {
+			<-killed
+			conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+			return
+		}
+		if reset != "" {
+			conn.ExecContext(context.Background(), reset)
+		}
+	}, nil
+}
+
+// Explain runs EXPLAIN FORMAT=JSON. MySQL reports EXPLAIN ANALYZE only as a
+// text tree, so with analyze that is run as well and returned as Analysis.
+func (mysqlDialect) Explain(ctx context.Context, q queryer, query string, args []interface{}, analyze bool) (*ExplainOutput, error) {
+	var raw []byte
+	if err := q.QueryRowContext(ctx, "EXPLAIN FORMAT=JSON "+query, args...).Scan(&raw); err != nil {
+		return nil, fmt.Errorf("explain failed: %w", err)
+	}
+	var plan map[string]interface{}
+	if err := json.Unmarshal(raw, &plan); err != nil {
+		return nil, fmt.Errorf("failed to parse plan: %w", err)
+	}
+
+	output := &ExplainOutput{Plan: plan}
+	if analyze {
+		if err := q.QueryRowContext(ctx, "EXPLAIN ANALYZE "+query, args...).Scan(&output.Analysis); err != nil {
+			return nil, fmt.Errorf("explain analyze failed: %w", err)
+		}
+	}
+
+	summary := &output.Summary
+	block, _ := plan["query_block"].(map[string]interface{})
+	costInfo, _ := block["cost_info"].(map[string]interface{})
+	summary.TotalCost = planNumber(costInfo["query_cost"])
+	summary.EstimatedRows = mysqlResultRows(block)
+
+	// Tables appear under a "table" key at any depth: in nested loops,
+	// below ordering and grouping operations, and in subqueries
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if table, ok := v["table"].(map[string]interface{}); ok {
+				mysqlTableSummary(summary, table)
+			}
+			if v["using_filesort"] == true {
+				if rows := mysqlResultRows(v); rows >= seqScanHintRows {
+					summary.Hints = append(summary.Hints, sortHint(rows, "the ORDER BY columns (filesort)"))
+				}
+			}
+			for _, key := range sortedKeys(v) {
+				walk(v[key])
+			}
+		case []interface{}:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(block)
+	return output, nil
+}
+
+// mysqlTableSummary adds one table access of a MySQL plan to summary.
+func mysqlTableSummary(summary *PlanSummary, table map[string]interface{}) {
+	name := fmt.Sprint(table["table_name"])
+	rows := int64(planNumber(table["rows_examined_per_scan"]))
+	summary.RowsScanned += rows
+
+	if table["access_type"] == "ALL" {
+		scan := PlanScan{Table: name, Rows: rows}
+		scan.Filter, _ = table["attached_condition"].(string)
+		summary.SeqScans = append(summary.SeqScans, scan)
+		if scan.Filter != "" && scan.Rows >= seqScanHintRows {
+			summary.Hints = append(summary.Hints, seqScanHint(scan))
+		}
+	}
+
+	if keys, ok := table["possible_keys"].([]interface{}); ok && len(keys) > 0 && table["key"] == nil {
+		names := make([]string, len(keys))
+		for i, key := range keys {
+			names[i] = fmt.Sprint(key)
+		}
+		summary.Hints = append(summary.Hints, fmt.Sprintf("Indexes %s on %s were considered but not used", strings.Join(names, ", "), name))
+	}
+}
+
+// mysqlResultRows estimates the rows a MySQL query block returns: the rows
+// produced by the last table of its join order.
+func mysqlResultRows(block map[string]interface{}) int64 {
+	for block != nil {
+		if table, ok := block["table"].(map[string]interface{}); ok {
+			return int64(planNumber(table["rows_produced_per_join"]))
+		}
+		if loop, ok := block["nested_loop"].([]interface{}); ok && len(loop) > 0 {
+			last, _ := loop[len(loop)-1].(map[string]interface{})
+			table, _ := last["table"].(map[string]interface{})
+			return int64(planNumber(table["rows_produced_per_join"]))
+		}
+
+		var next map[string]interface{}
+		for _, key := range []string{"ordering_operation", "grouping_operation", "duplicates_removal", "windowing"} {
+			if operation, ok := block[key].(map[string]interface{}); ok {
+				next = operation
+				break
+			}
+		}
+		block = next
+	}
+	return 0
+}
+
+// ILike lowercases both sides, since whether LIKE ignores case depends on the
+// column's collation.
+func (mysqlDialect) ILike(col string, value interface{}) sq.Sqlizer {
+	return sq.Expr("LOWER("+col+") LIKE LOWER(?)", value)
+}
+
+// SupportsReturning is false: MySQL has no RETURNING clause, so changed rows
+// are read back by primary key instead.
+func (mysqlDialect) SupportsReturning() bool {
+	return false
+}
+
+// UpsertClause ignores the target: MySQL reports a duplicate on any primary
+// key or unique index, which is why checkUpsertTarget requires the target to
+// be the only key the inserted columns can collide on.
+func (mysqlDialect) UpsertClause(target, update []string) string {
+	if len(update) == 0 {
+		// Assigning a column to itself leaves the row unchanged
+		return "ON DUPLICATE KEY UPDATE " + target[0] + " = " + target[0]
+	}
+	set := make([]string, len(update))
+	for i, col := range update {
+		set[i] = col + " = VALUES(" + col + ")"
+	}
+	return "ON DUPLICATE KEY UPDATE " + strings.Join(set, ", ")
+}
+
+func (d mysqlDialect) ListSchemas(ctx context.Context, db *sql.DB, database string) ([]string, error) {
+	return nil, nil
+}
+
+func (d mysqlDialect) ListTables(ctx context.Context, db *sql.DB, database, schema string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, "SHOW TABLES FROM "+d.QuoteIdentifier(database))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+	return tables, rows.Err()
+}
+
+func (d mysqlDialect) TableSchema(ctx context.Context, db *sql.DB, database, schema, table string) (*SchemaOutput, error) {
+	// Get columns - specify the database in the table reference
+	columnsQuery := `
+		SELECT 
+			COLUMN_NAME, DATA_TYPE, IS_NULLABLE, COLUMN_DEFAULT,
+			COLUMN_KEY, EXTRA, CHARACTER_MAXIMUM_LENGTH,
+			NUMERIC_PRECISION, NUMERIC_SCALE
+		FROM INFORMATION_SCHEMA.COLUMNS
+		WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?
+		ORDER BY ORDINAL_POSITION`
+
+	rows, err := db.QueryContext(ctx, columnsQuery, database, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	output := &SchemaOutput{
+		Database:    database,
+		Table:       table,
+		Columns:     []ColumnInfo{},
+		ForeignKeys: []ForeignKeyInfo{},
+		Indexes:     []IndexInfo{},
+	}
+
+	for rows.Next() {
+		var colName, dataType, isNullable, key, extra string
+		var colDefault sql.NullString
+		var charMaxLen, numPrecision, numScale sql.NullInt64
+
+		if err := rows.Scan(&colName, &dataType, &isNullable, &colDefault, &key, &extra, &charMaxLen, &numPrecision, &numScale); err != nil {
+			return nil, err
+		}
+
+		if charMaxLen.Valid {
+			dataType += fmt.Sprintf("(%d)", charMaxLen.Int64)
+		} else if numPrecision.Valid {
+			if numScale.Valid {
+				dataType += fmt.Sprintf("(%d,%d)", numPrecision.Int64, numScale.Int64)
+			} else {
+				dataType += fmt.Sprintf("(%d)", numPrecision.Int64)
+			}
+		}
+
+		output.Columns = append(output.Columns, ColumnInfo{
+			Name:       colName,
+			Type:       dataType,
+			Nullable:   isNullable == "YES",
+			Default:    colDefault.String,
+			PrimaryKey: key == "PRI",
+			Key:        key,
+			Extra:      extra,
+		})
+	}
+
+	// Get foreign keys
+	fkQuery := `
+		SELECT
+			COLUMN_NAME,
+			REFERENCED_TABLE_SCHEMA,
+			REFERENCED_TABLE_NAME,
+			REFERENCED_COLUMN_NAME
+		FROM INFORMATION_SCHEMA.KEY_COLUMN_USAGE
+		WHERE TABLE_SCHEMA = ?
+			AND TABLE_NAME = ?
+			AND REFERENCED_TABLE_NAME IS NOT NULL`
+
+	fkRows, err := db.QueryContext(ctx, fkQuery, database, table)
+	if err == nil {
+		defer fkRows.Close()
+		for fkRows.Next() {
+			var fk ForeignKeyInfo
+			fkRows.Scan(&fk.Column, &fk.ForeignSchema, &fk.ForeignTable, &fk.ForeignColumn)
+			output.ForeignKeys = append(output.ForeignKeys, fk)
+		}
+	}
+
+	// Get indexes
+	indexQuery := `
+		SELECT INDEX_NAME, NON_UNIQUE, COLUMN_NAME
+		FROM INFORMATION_SCHEMA.STATISTICS
+		WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? AND INDEX_NAME <> 'PRIMARY'
+		ORDER BY INDEX_NAME, SEQ_IN_INDEX`
+
+	indexRows, err := db.QueryContext(ctx, indexQuery, database, table)
+	if err == nil {
+		defer indexRows.Close()
+		for indexRows.Next() {
+			var name, column string
+			var nonUnique int
+			indexRows.Scan(&name, &nonUnique, &column)
+			// One row per indexed column, in index order
+			if n := len(output.Indexes); n == 0 || output.Indexes[n-1].Name != name {
+				output.Indexes = append(output.Indexes, IndexInfo{Name: name, Unique: nonUnique == 0})
+			}
+			idx := &output.Indexes[len(output.Indexes)-1]
+			idx.Columns = append(idx.Columns, column)
+		}
+	}
+
+	return output, nil
+}
+
+// Sequences lists auto_increment columns, MySQL's closest equivalent.
+func (d mysqlDialect) Sequences(ctx context.Context, db *sql.DB, database, schema string) (*SequencesOutput, error) {
+	query := `
+		SELECT 
+			TABLE_NAME, COLUMN_NAME, DATA_TYPE
+		FROM INFORMATION_SCHEMA.COLUMNS
+		WHERE TABLE_SCHEMA = ? AND EXTRA LIKE '%auto_increment%'
+		ORDER BY TABLE_NAME, ORDINAL_POSITION`
+
+	rows, err := db.QueryContext(ctx, query, database)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	output := &SequencesOutput{Database: database, Sequences: []SequenceInfo{}}
+	for rows.Next() {
+		var seq SequenceInfo
+		if err := rows.Scan(&seq.Table, &seq.Column, &seq.Type); err != nil {
+			return nil, err
+		}
+		seq.Name = seq.Table + "." + seq.Column
+		output.Sequences = append(output.Sequences, seq)
+	}
+	return output, rows.Err()
+}
+
+func (mysqlDialect) CustomTypes(ctx context.Context, db *sql.DB, database, schema string) (*CustomTypesOutput, error) {
+	return &CustomTypesOutput{
+		Database: database,
+		Types:    []CustomTypeInfo{},
+		Message:  "Custom types are only supported in PostgreSQL",
+	}, nil
+}
+
+func (d mysqlDialect) Functions(ctx context.Context, db *sql.DB, database, schema string) (*FunctionsOutput, error) {
+	query := `
+		SELECT 
+			r.ROUTINE_NAME as name,
+			LOWER(r.ROUTINE_TYPE) as type,
+			COALESCE(p.arguments, '') as arguments,
+			COALESCE(r.DTD_IDENTIFIER, '') as return_type,
+			r.ROUTINE_BODY as language,
+			CAST(r.CREATED AS CHAR) as created
+		FROM INFORMATION_SCHEMA.ROUTINES r
+		LEFT JOIN (
+			SELECT
+				SPECIFIC_NAME,
+				GROUP_CONCAT(
+					CONCAT_WS(' ', PARAMETER_MODE, PARAMETER_NAME, DTD_IDENTIFIER)
+					ORDER BY ORDINAL_POSITION SEPARATOR ', '
+				) as arguments
+			FROM INFORMATION_SCHEMA.PARAMETERS
+			WHERE SPECIFIC_SCHEMA = ? AND ORDINAL_POSITION > 0
+			GROUP BY SPECIFIC_NAME
+		) p ON p.SPECIFIC_NAME = r.SPECIFIC_NAME
+		WHERE r.ROUTINE_SCHEMA = ?
+		ORDER BY r.ROUTINE_NAME`
+
+	rows, err := db.QueryContext(ctx, query, database, database)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	output := &FunctionsOutput{Database: database, Functions: []FunctionInfo{}}
+	for rows.Next() {
+		var f FunctionInfo
+		if err := rows.Scan(&f.Name, &f.Kind, &f.Arguments, &f.ReturnType, &f.Language, &f.Created); err != nil {
+			return nil, err
+		}
+		output.Functions = append(output.Functions, f)
+	}
+	return output, rows.Err()
+}
+
+func (d mysqlDialect) FunctionSource(ctx context.Context, db *sql.DB, database, schema, name string) (*FunctionSourceOutput, error) {
+	query := `
+		SELECT 
+			ROUTINE_NAME as name,
+			LOWER(ROUTINE_TYPE) as type,
+			ROUTINE_DEFINITION as definition,
+			ROUTINE_SCHEMA
+		FROM INFORMATION_SCHEMA.ROUTINES
+		WHERE ROUTINE_SCHEMA = ? AND ROUTINE_NAME = ?`
+
+	rows, err := db.QueryContext(ctx, query, database, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	output := &FunctionSourceOutput{Schema: database, Name: name}
+	if !rows.Next() {
+		output.Message = fmt.Sprintf("Function or procedure '%s' not found in %s", name, database)
+		return output, nil
+	}
+
+	var definition sql.NullString
+	if err := rows.Scan(&output.Name, &output.Kind, &definition, &output.Schema); err != nil {
+		return nil, err
+	}
+	output.Definition = definition.String
+	if output.Definition == "" {
+		output.Message = "Source code not available"
+	}
+	return output, nil
+}
+
+func (d mysqlDialect) RoutineKind(ctx context.Context, db *sql.DB, database, schema, name string) (routineKind, error) {
+	query := `
+		SELECT ROUTINE_TYPE as type
+		FROM INFORMATION_SCHEMA.ROUTINES
+		WHERE ROUTINE_SCHEMA = ? AND ROUTINE_NAME = ?`
+
+	var routineType string
+	if err := db.QueryRowContext(ctx, query, database, name).Scan(&routineType); err != nil {
+		return 0, fmt.Errorf("function or procedure '%s' not found in %s", name, database)
+	}
+
+	if routineType == "PROCEDURE" {
+		return routineProcedure, nil
+	}
+	return routineFunction, nil
+}
+
+func (d mysqlDialect) RoutineCall(database, schema, name string, nparams int, kind routineKind) string {
+	placeholders := make([]string, nparams)
+	for i := range placeholders {
+		placeholders[i] = "?"
+	}
+	paramStr := strings.Join(placeholders, ", ")
+	qualifiedName := d.QuoteIdentifier(database) + "." + d.QuoteIdentifier(name)
+
+	if kind == routineProcedure {
+		return fmt.Sprintf("CALL %s(%s)", qualifiedName, paramStr)
+	}
+	return fmt.Sprintf("SELECT %s(%s) as result", qualifiedName, paramStr)
+}
@@ -0,0 +1,518 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+)
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string       { return "postgres" }
+func (postgresDialect) DriverName() string { return "postgres" }
+
+// DSN makes every transaction of a read-only connection READ ONLY by
+// default, in addition to the explicit READ ONLY transactions.
+func (postgresDialect) DSN(database string) string {
+	port := dbPort
+	if port == "" {
+		port = "5432"
+	}
+	dsn := fmt.Sprintf(
+		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+		dbHost, port, dbUser, dbPassword, database,
+	)
+	if readOnly {
+		dsn += " default_transaction_read_only=on"
+	}
+	return dsn
+}
+
+func (postgresDialect) MaxOpenConns() int {
+	return 0
+}
+
+// PlaceholderFormat uses PostgreSQL placeholders ($1, $2, etc.)
+func (postgresDialect) PlaceholderFormat() sq.PlaceholderFormat {
+	return sq.Dollar
+}
+
+func (postgresDialect) QuoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func (postgresDialect) schemaOrDefault(schema string) string {
+	if schema == "" {
+		return "public"
+	}
+	return schema
+}
+
+// QualifiedTable qualifies the table with its schema (default "public"),
+// which must exist.
+func (d postgresDialect) QualifiedTable(ctx context.Context, db *sql.DB, database, schema, table string) (string, error) {
+	schema = d.schemaOrDefault(schema)
+
+	var exists bool
+	err := db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM pg_namespace WHERE nspname = $1)", schema).Scan(&exists)
+	if err != nil {
+		return "", fmt.Errorf("failed to check schema: %w", err)
+	}
+	if !exists {
+		return "", fmt.Errorf("schema '%s' does not exist in database '%s'", schema, database)
+	}
+
+	return d.QuoteIdentifier(schema) + "." + d.QuoteIdentifier(table), nil
+}
+
+// PrepareConn sets statement_timeout. lib/pq cancels the running query
+// itself when ctx is done.
+func (postgresDialect) PrepareConn(ctx context.Context, db *sql.DB, conn *sql.Conn, timeout time.Duration) (func(), error) {
+	if timeout <= 0 {
+		return func() {}, nil
+	}
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf("SET statement_timeout = %d", timeout.Milliseconds())); err != nil {
+		return nil, err
+	}
+	return func() {
+		conn.ExecContext(context.Background(), "RESET statement_timeout")
+	}, nil
+}
+
+// Explain runs EXPLAIN (FORMAT JSON, VERBOSE); VERBOSE adds the schema of
+// each scanned table. Plan rows of a Seq Scan are the rows left after its
+// filter, so the size of a fully scanned table is taken from the statistics
+// in pg_class instead.
+func (postgresDialect) Explain(ctx context.Context, q queryer, query string, args []interface{}, analyze bool) (*ExplainOutput, error) {
+	options := "FORMAT JSON, VERBOSE"
+	if analyze {
+		options += ", ANALYZE, BUFFERS"
+	}
+
+	var raw []byte
+	if err := q.QueryRowContext(ctx, "EXPLAIN ("+options+") "+query, args...).Scan(&raw); err != nil {
+		return nil, fmt.Errorf("explain failed: %w", err)
+	}
+	var plans []map[string]interface{}
+	if err := json.Unmarshal(raw, &plans); err != nil {
+		return nil, fmt.Errorf("failed to parse plan: %w", err)
+	}
+	if len(plans) == 0 {
+		return nil, fmt.Errorf("explain returned no plan")
+	}
+
+	output := &ExplainOutput{Plan: plans[0]}
+	summary := &output.Summary
+	root, _ := plans[0]["Plan"].(map[string]interface{})
+	summary.TotalCost = planNumber(root["Total Cost"])
+	summary.EstimatedRows = int64(planNumber(root["Plan Rows"]))
+	summary.ExecutionMS = planNumber(plans[0]["Execution Time"])
+
+	var walk func(node map[string]interface{}) error
+	walk = func(node map[string]interface{}) error {
+		rows := int64(planNumber(node["Plan Rows"]))
+		switch node["Node Type"] {
+		case "Seq Scan":
+			scan := PlanScan{Table: fmt.Sprint(node["Relation Name"]), Rows: rows}
+			scan.Filter, _ = node["Filter"].(string)
+			if analyze {
+				scan.ActualRows = int64(planNumber(node["Actual Rows"]) * planNumber(node["Actual Loops"]))
+			}
+			var tuples int64
+			schema, _ := node["Schema"].(string)
+			statsQuery := `
+				SELECT COALESCE(MAX(c.reltuples), 0)::bigint
+				FROM pg_class c
+				JOIN pg_namespace n ON n.oid = c.relnamespace
+				WHERE n.nspname = $1 AND c.relname = $2`
+			err := q.QueryRowContext(ctx, statsQuery, schema, scan.Table).Scan(&tuples)
+			if err != nil {
+				return fmt.Errorf("failed to read table statistics: %w", err)
+			}
+			if tuples > scan.Rows {
+				scan.Rows = tuples
+			}
+
+			summary.SeqScans = append(summary.SeqScans, scan)
+			summary.RowsScanned += scan.Rows
+			if scan.Filter != "" && scan.Rows >= seqScanHintRows {
+				summary.Hints = append(summary.Hints, seqScanHint(scan))
+			}
+		case "Index Scan", "Index Only Scan", "Bitmap Heap Scan":
+			summary.RowsScanned += rows
+		case "Sort":
+			if rows >= seqScanHintRows {
+				var keys []string
+				if sortKeys, ok := node["Sort Key"].([]interface{}); ok {
+					for _, key := range sortKeys {
+						keys = append(keys, fmt.Sprint(key))
+					}
+				}
+				summary.Hints = append(summary.Hints, sortHint(rows, strings.Join(keys, ", ")))
+			}
+		}
+
+		children, _ := node["Plans"].([]interface{})
+		for _, child := range children {
+			if child, ok := child.(map[string]interface{}); ok {
+				if err := walk(child); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := walk(root); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+func (postgresDialect) ILike(col string, value interface{}) sq.Sqlizer {
+	return sq.ILike{col: value}
+}
+
+func (postgresDialect) SupportsReturning() bool {
+	return true
+}
+
+func (postgresDialect) UpsertClause(target, update []string) string {
+	clause := "ON CONFLICT (" + strings.Join(target, ", ") + ") DO "
+	if len(update) == 0 {
+		return clause + "NOTHING"
+	}
+	set := make([]string, len(update))
+	for i, col := range update {
+		set[i] = col + " = excluded." + col
+	}
+	return clause + "UPDATE SET " + strings.Join(set, ", ")
+}
+
+func (d postgresDialect) ListSchemas(ctx context.Context, db *sql.DB, database string) ([]string, error) {
+	query := `
+		SELECT nspname FROM pg_namespace
+		WHERE nspname NOT LIKE 'pg\_%' AND nspname <> 'information_schema'
+		ORDER BY nspname`
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schemas []string
+	for rows.Next() {
+		var schema string
+		if err := rows.Scan(&schema); err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, schema)
+	}
+	return schemas, rows.Err()
+}
+
+func (d postgresDialect) ListTables(ctx context.Context, db *sql.DB, database, schema string) ([]string, error) {
+	query := "SELECT tablename FROM pg_tables WHERE schemaname = $1 ORDER BY tablename"
+	rows, err := db.QueryContext(ctx, query, d.schemaOrDefault(schema))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+	return tables, rows.Err()
+}
+
+func (d postgresDialect) TableSchema(ctx context.Context, db *sql.DB, database, schema, table string) (*SchemaOutput, error) {
+	schema = d.schemaOrDefault(schema)
+
+	// Get columns
+	columnsQuery := `
+		SELECT 
+			c.column_name, c.data_type, c.is_nullable, c.column_default,
+			c.character_maximum_length, c.numeric_precision, c.numeric_scale,
+			EXISTS (
+				SELECT 1
+				FROM information_schema.table_constraints tc
+				JOIN information_schema.key_column_usage kcu
+					ON tc.constraint_name = kcu.constraint_name
+					AND tc.table_schema = kcu.table_schema
+				WHERE tc.constraint_type = 'PRIMARY KEY'
+					AND tc.table_schema = c.table_schema
+					AND tc.table_name = c.table_name
+					AND kcu.column_name = c.column_name
+			) AS is_primary
+		FROM information_schema.columns c
+		WHERE c.table_catalog = $1 AND c.table_schema = $2 AND c.table_name = $3
+		ORDER BY c.ordinal_position`
+
+	rows, err := db.QueryContext(ctx, columnsQuery, database, schema, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	output := &SchemaOutput{
+		Database:    database,
+		Schema:      schema,
+		Table:       table,
+		Columns:     []ColumnInfo{},
+		ForeignKeys: []ForeignKeyInfo{},
+		Indexes:     []IndexInfo{},
+	}
+
+	for rows.Next() {
+		var colName, dataType, isNullable string
+		var colDefault sql.NullString
+		var charMaxLen, numPrecision, numScale sql.NullInt64
+		var isPrimary bool
+
+		if err := rows.Scan(&colName, &dataType, &isNullable, &colDefault, &charMaxLen, &numPrecision, &numScale, &isPrimary); err != nil {
+			return nil, err
+		}
+
+		if charMaxLen.Valid {
+			dataType += fmt.Sprintf("(%d)", charMaxLen.Int64)
+		} else if numPrecision.Valid {
+			if numScale.Valid {
+				dataType += fmt.Sprintf("(%d,%d)", numPrecision.Int64, numScale.Int64)
+			} else {
+				dataType += fmt.Sprintf("(%d)", numPrecision.Int64)
+			}
+		}
+
+		output.Columns = append(output.Columns, ColumnInfo{
+			Name:       colName,
+			Type:       dataType,
+			Nullable:   isNullable == "YES",
+			Default:    colDefault.String,
+			PrimaryKey: isPrimary,
+		})
+	}
+
+	// Get foreign keys
+	fkQuery := `
+		SELECT
+			kcu.column_name,
+			ccu.table_schema AS foreign_schema,
+			ccu.table_name AS foreign_table,
+			ccu.column_name AS foreign_column
+		FROM information_schema.table_constraints AS tc
+		JOIN information_schema.key_column_usage AS kcu
+			ON tc.constraint_name = kcu.constraint_name
+			AND tc.table_schema = kcu.table_schema
+		JOIN information_schema.constraint_column_usage AS ccu
+			ON ccu.constraint_name = tc.constraint_name
+			AND ccu.table_schema = tc.table_schema
+		WHERE tc.constraint_type = 'FOREIGN KEY'
+			AND tc.table_schema = $1
+			AND tc.table_name = $2`
+
+	fkRows, err := db.QueryContext(ctx, fkQuery, schema, table)
+	if err == nil {
+		defer fkRows.Close()
+		for fkRows.Next() {
+			var fk ForeignKeyInfo
+			fkRows.Scan(&fk.Column, &fk.ForeignSchema, &fk.ForeignTable, &fk.ForeignColumn)
+			output.ForeignKeys = append(output.ForeignKeys, fk)
+		}
+	}
+
+	// Get indexes
+	indexQuery := `
+		SELECT indexname, indexdef
+		FROM pg_indexes
+		WHERE schemaname = $1 AND tablename = $2`
+
+	indexRows, err := db.QueryContext(ctx, indexQuery, schema, table)
+	if err == nil {
+		defer indexRows.Close()
+		for indexRows.Next() {
+			var idx IndexInfo
+			indexRows.Scan(&idx.Name, &idx.Definition)
+			idx.Unique = strings.HasPrefix(idx.Definition, "CREATE UNIQUE INDEX")
+			output.Indexes = append(output.Indexes, idx)
+		}
+	}
+
+	return output, nil
+}
+
+func (d postgresDialect) Sequences(ctx context.Context, db *sql.DB, database, schema string) (*SequencesOutput, error) {
+	schema = d.schemaOrDefault(schema)
+
+	query := `
+		SELECT sequence_name, data_type, start_value, minimum_value, maximum_value, increment
+		FROM information_schema.sequences
+		WHERE sequence_catalog = $1 AND sequence_schema = $2
+		ORDER BY sequence_name`
+
+	rows, err := db.QueryContext(ctx, query, database, schema)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	output := &SequencesOutput{Database: database, Schema: schema, Sequences: []SequenceInfo{}}
+	for rows.Next() {
+		var seq SequenceInfo
+		if err := rows.Scan(&seq.Name, &seq.Type, &seq.Start, &seq.Min, &seq.Max, &seq.Increment); err != nil {
+			return nil, err
+		}
+		output.Sequences = append(output.Sequences, seq)
+	}
+	return output, rows.Err()
+}
+
+func (d postgresDialect) CustomTypes(ctx context.Context, db *sql.DB, database, schema string) (*CustomTypesOutput, error) {
+	schema = d.schemaOrDefault(schema)
+
+	query := `
+		SELECT 
+			t.typname as type_name,
+			CASE t.typtype
+				WHEN 'e' THEN 'enum'
+				WHEN 'c' THEN 'composite'
+				WHEN 'd' THEN 'domain'
+				WHEN 'b' THEN 'base'
+				ELSE 'other'
+			END as type_category,
+			(SELECT array_agg(e.enumlabel ORDER BY e.enumsortorder)
+			 FROM pg_enum e WHERE e.enumtypid = t.oid) as enum_values
+		FROM pg_type t
+		JOIN pg_namespace n ON n.oid = t.typnamespace
+		WHERE n.nspname = $1 AND t.typtype IN ('e', 'c', 'd')
+		ORDER BY t.typname`
+
+	rows, err := db.QueryContext(ctx, query, schema)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	output := &CustomTypesOutput{Database: database, Schema: schema, Types: []CustomTypeInfo{}}
+	for rows.Next() {
+		var typ CustomTypeInfo
+		if err := rows.Scan(&typ.Name, &typ.Category, pq.Array(&typ.Values)); err != nil {
+			return nil, err
+		}
+		output.Types = append(output.Types, typ)
+	}
+	return output, rows.Err()
+}
+
+func (d postgresDialect) Functions(ctx context.Context, db *sql.DB, database, schema string) (*FunctionsOutput, error) {
+	schema = d.schemaOrDefault(schema)
+
+	query := `
+		SELECT 
+			p.proname as name,
+			CASE p.prokind
+				WHEN 'f' THEN 'function'
+				WHEN 'p' THEN 'procedure'
+				WHEN 'a' THEN 'aggregate'
+				WHEN 'w' THEN 'window'
+			END as type,
+			pg_catalog.pg_get_function_arguments(p.oid) as arguments,
+			COALESCE(pg_catalog.pg_get_function_result(p.oid), '') as return_type,
+			l.lanname as language
+		FROM pg_proc p
+		JOIN pg_namespace n ON n.oid = p.pronamespace
+		JOIN pg_language l ON l.oid = p.prolang
+		WHERE n.nspname = $1
+		ORDER BY p.proname`
+
+	rows, err := db.QueryContext(ctx, query, schema)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	output := &FunctionsOutput{Database: database, Schema: schema, Functions: []FunctionInfo{}}
+	for rows.Next() {
+		var f FunctionInfo
+		if err := rows.Scan(&f.Name, &f.Kind, &f.Arguments, &f.ReturnType, &f.Language); err != nil {
+			return nil, err
+		}
+		output.Functions = append(output.Functions, f)
+	}
+	return output, rows.Err()
+}
+
+func (d postgresDialect) FunctionSource(ctx context.Context, db *sql.DB, database, schema, name string) (*FunctionSourceOutput, error) {
+	schema = d.schemaOrDefault(schema)
+
+	query := `
+		SELECT 
+			p.proname as name,
+			CASE p.prokind
+				WHEN 'f' THEN 'function'
+				WHEN 'p' THEN 'procedure'
+			END as type,
+			pg_catalog.pg_get_functiondef(p.oid) as definition
+		FROM pg_proc p
+		JOIN pg_namespace n ON n.oid = p.pronamespace
+		WHERE n.nspname = $1 AND p.proname = $2 AND p.prokind IN ('f', 'p')`
+
+	rows, err := db.QueryContext(ctx, query, schema, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	output := &FunctionSourceOutput{Schema: schema, Name: name}
+	if !rows.Next() {
+		output.Message = fmt.Sprintf("Function or procedure '%s' not found in %s", name, schema)
+		return output, nil
+	}
+
+	if err := rows.Scan(&output.Name, &output.Kind, &output.Definition); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+func (d postgresDialect) RoutineKind(ctx context.Context, db *sql.DB, database, schema, name string) (routineKind, error) {
+	schema = d.schemaOrDefault(schema)
+
+	query := `
+		SELECT prokind FROM pg_proc p
+		JOIN pg_namespace n ON n.oid = p.pronamespace
+		WHERE n.nspname = $1 AND p.proname = $2`
+
+	var prokind string
+	if err := db.QueryRowContext(ctx, query, schema, name).Scan(&prokind); err != nil {
+		return 0, fmt.Errorf("function or procedure '%s' not found in %s", name, schema)
+	}
+
+	if prokind == "p" {
+		return routineProcedure, nil
+	}
+	return routineFunction, nil
+}
+
+func (d postgresDialect) RoutineCall(database, schema, name string, nparams int, kind routineKind) string {
+	placeholders := make([]string, nparams)
+	for i := range placeholders {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+	paramStr := strings.Join(placeholders, ", ")
+	qualifiedName := d.QuoteIdentifier(d.schemaOrDefault(schema)) + "." + d.QuoteIdentifier(name)
+
+	if kind == routineProcedure {
+		return fmt.Sprintf("CALL %s(%s)", qualifiedName, paramStr)
+	}
+	return fmt.Sprintf("SELECT %s(%s) as result", qualifiedName, paramStr)
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	_ "modernc.org/sqlite"
+)
+
+// sqliteDialect targets SQLite files. Each DB_NAME entry is a file path (or
+// ":memory:") and is used verbatim as the DSN. The schema argument selects an
+// attached database and defaults to "main".
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string       { return "sqlite" }
+func (sqliteDialect) DriverName() string { return "sqlite" }
+
+// DSN enables PRAGMA query_only on read-only connections: SQLite ignores READ
+// ONLY on BEGIN, so this is what makes it reject writes.
+func (sqliteDialect) DSN(database string) string {
+	if !readOnly {
+		return database
+	}
+	separator := "?"
+	if strings.Contains(database, "?") {
+		separator = "&"
+	}
+	return database + separator + "_pragma=query_only(1)"
+}
+
+// MaxOpenConns is 1 so that an in-memory database is shared by every query
+// and writers never contend for the file lock.
+func (sqliteDialect) MaxOpenConns() int {
+	return 1
+}
+
+// PlaceholderFormat uses SQLite placeholders (?)
+func (sqliteDialect) PlaceholderFormat() sq.PlaceholderFormat {
+	return sq.Question
+}
+
+func (sqliteDialect) QuoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func (sqliteDialect) schemaOrDefault(schema string) string {
+	if schema == "" {
+		return "main"
+	}
+	return schema
+}
+
+// QualifiedTable qualifies the table with its attached database name
+// (default "main"), which must exist.
+func (d sqliteDialect) QualifiedTable(ctx context.Context, db *sql.DB, database, schema, table string) (string, error) {
+	schema = d.schemaOrDefault(schema)
+
+	var exists bool
+	err := db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM pragma_database_list WHERE name = ?)", schema).Scan(&exists)
+	if err != nil {
+		return "", fmt.Errorf("failed to check schema: %w", err)
+	}
+	if !exists {
+		return "", fmt.Errorf("schema '%s' does not exist in database '%s'", schema, database)
+	}
+
+	return d.QuoteIdentifier(schema) + "." + d.QuoteIdentifier(table), nil
+}
+
+// PrepareConn does nothing: SQLite has no statement timeout setting, and the
+// driver interrupts the running query itself when ctx is done.
+func (sqliteDialect) PrepareConn(ctx context.Context, db *sql.DB, conn *sql.Conn, timeout time.Duration) (func(), error) {
+	return func() {}, nil
+}
+
+// sqlitePlanNode is a step of an EXPLAIN QUERY PLAN, nested under its parent.
+type sqlitePlanNode struct {
+	Detail   string            `json:"detail"`
+	Children []*sqlitePlanNode `json:"children,omitempty"`
+}
+
+// Explain runs EXPLAIN QUERY PLAN, which reports neither costs nor row
+// estimates, only how each table is accessed.
+func (sqliteDialect) Explain(ctx context.Context, q queryer, query string, args []interface{}, analyze bool) (*ExplainOutput, error) {
+	if analyze {
+		return nil, fmt.Errorf("analyze is not supported on SQLite")
+	}
+
+	rows, err := q.QueryContext(ctx, "EXPLAIN QUERY PLAN "+query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("explain failed: %w", err)
+	}
+	defer rows.Close()
+
+	output := &ExplainOutput{}
+	summary := &output.Summary
+	root := &sqlitePlanNode{}
+	nodes := map[int64]*sqlitePlanNode{0: root}
+	for rows.Next() {
+		var id, parent, notUsed int64
+		node := &sqlitePlanNode{}
+		if err := rows.Scan(&id, &parent, &notUsed, &node.Detail); err != nil {
+			return nil, err
+		}
+		if nodes[parent] == nil {
+			nodes[parent] = root
+		}
+		nodes[parent].Children = append(nodes[parent].Children, node)
+		nodes[id] = node
+
+		// SCAN t reads all of t, possibly in the order of an index; SEARCH
+		// looks rows up by index
+		words := strings.Fields(node.Detail)
+		switch {
+		case len(words) >= 2 && words[0] == "SCAN":
+			table := words[1]
+			if table == "TABLE" && len(words) >= 3 {
+				table = words[2]
+			}
+			if !strings.HasPrefix(table, "(") && table != "CONSTANT" {
+				summary.SeqScans = append(summary.SeqScans, PlanScan{Table: table})
+			}
+		case strings.Contains(node.Detail, "AUTOMATIC"):
+			summary.Hints = append(summary.Hints, fmt.Sprintf("SQLite builds a temporary index for %q on every run; a permanent index would avoid that", node.Detail))
+		case strings.HasPrefix(node.Detail, "USE TEMP B-TREE FOR ORDER BY"):
+			summary.Hints = append(summary.Hints, sortHint(0, "the ORDER BY columns"))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	output.Plan = root.Children
+	return output, nil
+}
+
+// ILike lowercases both sides, since SQLite's LIKE only ignores the case of
+// ASCII letters.
+func (sqliteDialect) ILike(col string, value interface{}) sq.Sqlizer {
+	return sq.Expr("LOWER("+col+") LIKE LOWER(?)", value)
+}
+
+func (sqliteDialect) SupportsReturning() bool {
+	return true
+}
+
+func (sqliteDialect) UpsertClause(target, update []string) string {
+	clause := "ON CONFLICT (" + strings.Join(target, ", ") + ") DO "
+	if len(update) == 0 {
+		return clause + "NOTHING"
+	}
+	set := make([]string, len(update))
+	for i, col := range update {
+		set[i] = col + " = excluded." + col
+	}
+	return clause + "UPDATE SET " + strings.Join(set, ", ")
+}
+
+func (d sqliteDialect) ListSchemas(ctx context.Context, db *sql.DB, database string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT name FROM pragma_database_list ORDER BY seq")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schemas []string
+	for rows.Next() {
+		var schema string
+		if err := rows.Scan(&schema); err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, schema)
+	}
+	return schemas, rows.Err()
+}
+
+func (d sqliteDialect) ListTables(ctx context.Context, db *sql.DB, database, schema string) ([]string, error) {
+	query := fmt.Sprintf(
+		"SELECT name FROM %s.sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%%' ORDER BY name",
+		d.QuoteIdentifier(d.schemaOrDefault(schema)),
+	)
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+	return tables, rows.Err()
+}
+
+func (d sqliteDialect) TableSchema(ctx context.Context, db *sql.DB, database, schema, table string) (*SchemaOutput, error) {
+	schema = d.schemaOrDefault(schema)
+
+	// Get columns
+	columnsQuery := `SELECT name, type, "notnull", dflt_value, pk FROM pragma_table_info(?, ?) ORDER BY cid`
+
+	rows, err := db.QueryContext(ctx, columnsQuery, table, schema)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	output := &SchemaOutput{
+		Database:    database,
+		Schema:      schema,
+		Table:       table,
+		Columns:     []ColumnInfo{},
+		ForeignKeys: []ForeignKeyInfo{},
+		Indexes:     []IndexInfo{},
+	}
+
+	for rows.Next() {
+		var col ColumnInfo
+		var notNull, pk int
+		var colDefault sql.NullString
+
+		if err := rows.Scan(&col.Name, &col.Type, &notNull, &colDefault, &pk); err != nil {
+			return nil, err
+		}
+
+		col.Nullable = notNull == 0
+		col.Default = colDefault.String
+		col.PrimaryKey = pk > 0
+		output.Columns = append(output.Columns, col)
+	}
+
+	// Get foreign keys
+	fkQuery := `SELECT "from", "table", "to" FROM pragma_foreign_key_list(?, ?) ORDER BY id, seq`
+
+	fkRows, err := db.QueryContext(ctx, fkQuery, table, schema)
+	if err == nil {
+		defer fkRows.Close()
+		for fkRows.Next() {
+			fk := ForeignKeyInfo{ForeignSchema: schema}
+			var fkColumn sql.NullString
+			fkRows.Scan(&fk.Column, &fk.ForeignTable, &fkColumn)
+			fk.ForeignColumn = fkColumn.String
+			output.ForeignKeys = append(output.ForeignKeys, fk)
+		}
+	}
+
+	// Get indexes
+	indexQuery := `SELECT name, "unique" FROM pragma_index_list(?, ?) WHERE origin <> 'pk' ORDER BY name`
+
+	indexRows, err := db.QueryContext(ctx, indexQuery, table, schema)
+	if err == nil {
+		defer indexRows.Close()
+		for indexRows.Next() {
+			var idx IndexInfo
+			var unique int
+			indexRows.Scan(&idx.Name, &unique)
+			idx.Unique = unique == 1
+			output.Indexes = append(output.Indexes, idx)
+		}
+	}
+
+	return output, nil
+}
+
+// Sequences reports the AUTOINCREMENT counters kept in sqlite_sequence, which
+// only exists once a table declared with AUTOINCREMENT has been created.
+func (d sqliteDialect) Sequences(ctx context.Context, db *sql.DB, database, schema string) (*SequencesOutput, error) {
+	schema = d.schemaOrDefault(schema)
+	output := &SequencesOutput{Database: database, Schema: schema, Sequences: []SequenceInfo{}}
+
+	var exists bool
+	existsQuery := fmt.Sprintf(
+		"SELECT EXISTS (SELECT 1 FROM %s.sqlite_master WHERE type = 'table' AND name = 'sqlite_sequence')",
+		d.QuoteIdentifier(schema),
+	)
+	if err := db.QueryRowContext(ctx, existsQuery).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return output, nil
+	}
+
+	query := fmt.Sprintf("SELECT name, seq FROM %s.sqlite_sequence ORDER BY name", d.QuoteIdentifier(schema))
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var seq SequenceInfo
+		var current int64
+		if err := rows.Scan(&seq.Name, &current); err != nil {
+			return nil, err
+		}
+		seq.Table = seq.Name
+		seq.Current = &current
+		output.Sequences = append(output.Sequences, seq)
+	}
+	return output, rows.Err()
+}
+
+func (sqliteDialect) CustomTypes(ctx context.Context, db *sql.DB, database, schema string) (*CustomTypesOutput, error) {
+	return &CustomTypesOutput{
+		Database: database,
+		Types:    []CustomTypeInfo{},
+		Message:  "Custom types are not supported in SQLite",
+	}, nil
+}
+
+func (sqliteDialect) Functions(ctx context.Context, db *sql.DB, database, schema string) (*FunctionsOutput, error) {
+	return &FunctionsOutput{
+		Database:  database,
+		Functions: []FunctionInfo{},
+		Message:   "Stored functions and procedures are not supported in SQLite",
+	}, nil
+}
+
+func (sqliteDialect) FunctionSource(ctx context.Context, db *sql.DB, database, schema, name string) (*FunctionSourceOutput, error) {
+	return &FunctionSourceOutput{
+		Name:    name,
+		Message: "Stored functions and procedures are not supported in SQLite",
+	}, nil
+}
+
+func (sqliteDialect) RoutineKind(ctx context.Context, db *sql.DB, database, schema, name string) (routineKind, error) {
+	return 0, fmt.Errorf("stored functions and procedures are not supported in SQLite")
+}
+
+func (sqliteDialect) RoutineCall(database, schema, name string, nparams int, kind routineKind) string {
+	return ""
+}
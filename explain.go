@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// explainVerbs are the raw statements explain_query accepts.
+var explainVerbs = map[string]bool{
+	"SELECT": true, "VALUES": true, "TABLE": true,
+	"INSERT": true, "UPDATE": true, "DELETE": true, "REPLACE": true, "MERGE": true,
+}
+
+// seqScanHintRows is the estimated table size from which a filtered full
+// scan is reported as a possible missing index; smaller tables are cheaper
+// to scan than to look up.
+const seqScanHintRows = 1000
+
+func ExplainQuery(ctx context.Context, req *mcp.CallToolRequest, input ExplainQueryInput) (*mcp.CallToolResult, ExplainOutput, error) {
+	database, stmt, err := explainStatement(ctx, input)
+	if err != nil {
+		return nil, ExplainOutput{}, err
+	}
+	pool, err := getPool(database)
+	if err != nil {
+		return nil, ExplainOutput{}, err
+	}
+
+	output, err := explain(ctx, pool, stmt, input.Analyze, timeoutFor(input.TimeoutMS))
+	if err != nil {
+		return nil, ExplainOutput{}, err
+	}
+	output.Database = database
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{
+				Text: formatExplain(output),
+			},
+		},
+	}, *output, nil
+}
+
+// explainStatement builds the statement to explain from either the
+// structured select or the raw query of input.
+func explainStatement(ctx context.Context, input ExplainQueryInput) (string, statement, error) {
+	if (input.Select == nil) == (input.Query == "") {
+		return "", statement{}, fmt.Errorf("set exactly one of select or query")
+	}
+
+	if input.Select != nil {
+		pool, err := getPool(input.Select.Database)
+		if err != nil {
+			return "", statement{}, err
+		}
+		stmt, err := buildSelect(ctx, pool, *input.Select)
+		return input.Select.Database, stmt, err
+	}
+
+	if !allowRawQuery {
+		return "", statement{}, fmt.Errorf("raw SQL queries are blocked. Set ALLOW_RAW_QUERY=true to explain raw SQL, or pass a structured select")
+	}
+	statements, err := classifySQL(input.Query)
+	if err != nil {
+		return "", statement{}, err
+	}
+	if len(statements) != 1 {
+		return "", statement{}, fmt.Errorf("query must contain exactly one statement, found %d", len(statements))
+	}
+	if !explainVerbs[statements[0].verb] {
+		return "", statement{}, fmt.Errorf("%s statements cannot be explained", statements[0].verb)
+	}
+	if readOnly && !statements[0].readOnly {
+		return "", statement{}, fmt.Errorf("database is in read-only mode: %s statements are not allowed", statements[0].verb)
+	}
+	return input.Database, statement{sql: statements[0].text, args: input.Params}, nil
+}
+
+// explain runs the dialect's EXPLAIN of stmt in a transaction that is always
+// rolled back, so that ANALYZE, which executes the statement, changes nothing.
+func explain(ctx context.Context, pool *dbPool, stmt statement, analyze bool, timeout time.Duration) (*ExplainOutput, error) {
+	var output *ExplainOutput
+	err := withConn(ctx, pool.db, timeout, func(ctx context.Context, conn *sql.Conn) error {
+		tx, err := conn.BeginTx(ctx, &sql.TxOptions{ReadOnly: readOnly})
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer tx.Rollback()
+
+		output, err = dialect.Explain(ctx, tx, stmt.sql, stmt.args, analyze)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	output.SQL = stmt.sql
+	output.Args = stmt.args
+	output.Analyzed = analyze
+	if output.Summary.SeqScans == nil {
+		output.Summary.SeqScans = []PlanScan{}
+	}
+	if output.Summary.Hints == nil {
+		output.Summary.Hints = []string{}
+	}
+	return output, nil
+}
+
+// checkCost is the cost guard: it explains stmt on q and refuses it, with
+// the plan summary, when its estimated cost or rows scanned exceed
+// MAX_QUERY_COST or MAX_ROWS_SCANNED for database.
+func checkCost(ctx context.Context, q queryer, database string, stmt statement) error {
+	maxCost, maxRows := maxQueryCost.of(database), maxRowsScanned.of(database)
+	if maxCost <= 0 && maxRows <= 0 {
+		return nil
+	}
+
+	output, err := dialect.Explain(ctx, q, stmt.sql, stmt.args, false)
+	if err != nil {
+		return fmt.Errorf("cost guard: %w", err)
+	}
+
+	summary := output.Summary
+	var reason string
+	switch {
+	case maxCost > 0 && summary.TotalCost > maxCost:
+		reason = fmt.Sprintf("estimated cost %.2f exceeds MAX_QUERY_COST of %g", summary.TotalCost, maxCost)
+	case maxRows > 0 && float64(summary.RowsScanned) > maxRows:
+		reason = fmt.Sprintf("estimated %d rows scanned exceeds MAX_ROWS_SCANNED of %g", summary.RowsScanned, maxRows)
+	default:
+		return nil
+	}
+	return fmt.Errorf("query refused by the cost guard on '%s': %s\n%s", database, reason, formatPlanSummary(summary))
+}
+
+// costChecked reports whether the cost guard applies to a raw statement: a
+// read that returns rows and can be explained, such as SELECT, TABLE or
+// VALUES.
+func costChecked(stmt sqlStatement) bool {
+	return readVerbs[stmt.verb] && stmt.returnsRows && explainVerbs[stmt.verb]
+}
+
+// seqScanHint describes a full scan of a large table that filters its rows.
+func seqScanHint(scan PlanScan) string {
+	return fmt.Sprintf("Full scan of %s reads ~%d rows to apply %s; an index on the filtered column(s) may help", scan.Table, scan.Rows, scan.Filter)
+}
+
+// sortHint describes a sort the database has to perform itself.
+func sortHint(rows int64, key string) string {
+	if rows > 0 {
+		return fmt.Sprintf("Sorting ~%d rows by %s; an index matching the ORDER BY may avoid the sort", rows, key)
+	}
+	return fmt.Sprintf("Sorting by %s; an index matching the ORDER BY may avoid the sort", key)
+}
+
+// planNumber reads a number from a decoded JSON plan, where MySQL reports
+// some figures as strings.
+func planNumber(v interface{}) float64 {
+	switch v := v.(type) {
+	case float64:
+		return v
+	case string:
+		n, _ := strconv.ParseFloat(v, 64)
+		return n
+	}
+	return 0
+}
+
+// sortedKeys returns the keys of a decoded JSON object in order, so that
+// plans are walked deterministically.
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
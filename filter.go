@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// buildFilter compiles a list of conditions, which are ANDed together, into a
+// single condition. column quotes the column of each comparison and may
+// validate it or resolve it to an expression, such as an aggregate in HAVING.
+func buildFilter(clauses []WhereClause, column func(string) (string, error)) (sq.Sqlizer, error) {
+	conditions := make(sq.And, 0, len(clauses))
+	for _, clause := range clauses {
+		condition, err := buildCondition(clause, column)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, condition)
+	}
+	return conditions, nil
+}
+
+// buildCondition compiles one condition: a comparison on a column, or an
+// and/or/not group of nested conditions. A not group negates the AND of its
+// conditions.
+func buildCondition(clause WhereClause, column func(string) (string, error)) (sq.Sqlizer, error) {
+	set := 0
+	for _, ok := range []bool{clause.Column != "", clause.And != nil, clause.Or != nil, clause.Not != nil} {
+		if ok {
+			set++
+		}
+	}
+	if set != 1 {
+		return nil, fmt.Errorf("each condition must set exactly one of column, and, or, not")
+	}
+
+	var group []WhereClause
+	var name string
+	switch {
+	case clause.And != nil:
+		group, name = clause.And, "and"
+	case clause.Or != nil:
+		group, name = clause.Or, "or"
+	case clause.Not != nil:
+		group, name = clause.Not, "not"
+	default:
+		col, err := column(clause.Column)
+		if err != nil {
+			return nil, err
+		}
+		return whereCondition(col, clause)
+	}
+
+	// An empty group would match every row, which must not slip past the
+	// WHERE requirement of UPDATE and DELETE.
+	if len(group) == 0 {
+		return nil, fmt.Errorf("%s group must contain at least one condition", name)
+	}
+	nested, err := buildFilter(group, column)
+	if err != nil {
+		return nil, err
+	}
+	switch name {
+	case "or":
+		return sq.Or(nested.(sq.And)), nil
+	case "not":
+		return sq.Expr("NOT ?", nested), nil
+	default:
+		return nested, nil
+	}
+}
+
+// whereOperators are the comparison operators a condition may use.
+var whereOperators = []string{
+	"=", "!=", "<>", "<", "<=", ">", ">=",
+	"LIKE", "NOT LIKE", "ILIKE", "NOT ILIKE",
+	"IN", "NOT IN", "BETWEEN", "NOT BETWEEN",
+	"IS NULL", "IS NOT NULL",
+}
+
+// whereCondition compares col, a quoted column or an aggregate expression,
+// using the operator and value of clause. Operators outside whereOperators
+// are rejected rather than passed through to SQL.
+func whereCondition(col string, clause WhereClause) (sq.Sqlizer, error) {
+	op := strings.Join(strings.Fields(strings.ToUpper(clause.Op)), " ")
+
+	switch op {
+	case "IS NULL":
+		return sq.Eq{col: nil}, nil
+	case "IS NOT NULL":
+		return sq.NotEq{col: nil}, nil
+	case "IN", "NOT IN":
+		values, ok := clause.Value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s on column '%s' requires an array value", op, clause.Column)
+		}
+		if op == "IN" {
+			return sq.Eq{col: values}, nil
+		}
+		return sq.NotEq{col: values}, nil
+	case "BETWEEN", "NOT BETWEEN":
+		values, ok := clause.Value.([]interface{})
+		if !ok || len(values) != 2 {
+			return nil, fmt.Errorf("%s on column '%s' requires an array of two values", op, clause.Column)
+		}
+		return sq.Expr(col+" "+op+" ? AND ?", values[0], values[1]), nil
+	}
+
+	switch clause.Value.(type) {
+	case []interface{}, map[string]interface{}:
+		return nil, fmt.Errorf("%s on column '%s' requires a single value", clause.Op, clause.Column)
+	}
+
+	switch op {
+	case "=":
+		return sq.Eq{col: clause.Value}, nil
+	case "!=", "<>":
+		return sq.NotEq{col: clause.Value}, nil
+	case ">":
+		return sq.Gt{col: clause.Value}, nil
+	case ">=":
+		return sq.GtOrEq{col: clause.Value}, nil
+	case "<":
+		return sq.Lt{col: clause.Value}, nil
+	case "<=":
+		return sq.LtOrEq{col: clause.Value}, nil
+	case "LIKE":
+		return sq.Like{col: clause.Value}, nil
+	case "NOT LIKE":
+		return sq.NotLike{col: clause.Value}, nil
+	case "ILIKE":
+		return dialect.ILike(col, clause.Value), nil
+	case "NOT ILIKE":
+		return sq.Expr("NOT (?)", dialect.ILike(col, clause.Value)), nil
+	default:
+		return nil, fmt.Errorf("unsupported operator '%s' on column '%s' (expected one of: %s)", clause.Op, clause.Column, strings.Join(whereOperators, ", "))
+	}
+}
+
+// whereColumn returns the column resolver for WHERE conditions. With a join
+// scope, references are validated against the joined tables.
+func whereColumn(scope *joinScope) func(string) (string, error) {
+	return func(column string) (string, error) {
+		if scope != nil {
+			if err := scope.check(column); err != nil {
+				return "", fmt.Errorf("invalid WHERE column: %w", err)
+			}
+		}
+		col, err := quoteIdentifier(column)
+		if err != nil {
+			return "", fmt.Errorf("invalid WHERE column: %w", err)
+		}
+		return col, nil
+	}
+}
+
+// inputSchema infers the input schema of a tool whose input contains
+// conditions. Schemas cannot be inferred for the recursive WhereClause, so
+// condition lists refer to a "filter" definition instead.
+func inputSchema[T any]() *jsonschema.Schema {
+	opts := &jsonschema.ForOptions{TypeSchemas: map[reflect.Type]*jsonschema.Schema{
+		reflect.TypeFor[[]WhereClause](): {Type: "array", Items: &jsonschema.Schema{Ref: "#/$defs/filter"}},
+	}}
+	filter, err := jsonschema.For[WhereClause](opts)
+	if err != nil {
+		panic(err)
+	}
+	schema, err := jsonschema.For[T](opts)
+	if err != nil {
+		panic(err)
+	}
+	schema.Defs = map[string]*jsonschema.Schema{"filter": filter}
+	return schema
+}
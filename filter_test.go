@@ -0,0 +1,116 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildFilter(t *testing.T) {
+	tests := []struct {
+		name  string
+		where []WhereClause
+		sql   string
+		args  []interface{}
+	}{
+		{"comparisons are ANDed", []WhereClause{{Column: "a", Op: "=", Value: 1}, {Column: "b", Op: "is null"}}, `("a" = ? AND "b" IS NULL)`, []interface{}{1}},
+		{"operator case and spacing", []WhereClause{{Column: "a", Op: " not   like ", Value: "x%"}}, `("a" NOT LIKE ?)`, []interface{}{"x%"}},
+		{"not equal to null", []WhereClause{{Column: "a", Op: "!=", Value: nil}}, `("a" IS NOT NULL)`, nil},
+		{"in", []WhereClause{{Column: "a", Op: "IN", Value: []interface{}{1, 2}}}, `("a" IN (?,?))`, []interface{}{1, 2}},
+		{"not between", []WhereClause{{Column: "a", Op: "NOT BETWEEN", Value: []interface{}{1, 9}}}, `("a" NOT BETWEEN ? AND ?)`, []interface{}{1, 9}},
+		{"dotted column", []WhereClause{{Column: "t.a", Op: ">=", Value: 3}}, `("t"."a" >= ?)`, []interface{}{3}},
+		{"quoted column", []WhereClause{{Column: `a"; DROP TABLE t; --`, Op: "=", Value: 1}}, `("a""; DROP TABLE t; --" = ?)`, []interface{}{1}},
+		{"or group", []WhereClause{{Or: []WhereClause{{Column: "a", Op: "<", Value: 1}, {Column: "b", Op: "in", Value: []interface{}{1, 2}}}}}, `(("a" < ? OR "b" IN (?,?)))`, []interface{}{1, 1, 2}},
+		{"and group", []WhereClause{{And: []WhereClause{{Column: "a", Op: "=", Value: 1}, {Column: "b", Op: "=", Value: 2}}}}, `(("a" = ? AND "b" = ?))`, []interface{}{1, 2}},
+		{"nested not and or", []WhereClause{{Not: []WhereClause{
+			{Column: "a", Op: "like", Value: "x%"},
+			{Or: []WhereClause{{Column: "b", Op: "between", Value: []interface{}{1, 2}}, {Column: "c", Op: "not ilike", Value: "y"}}},
+		}}}, `(NOT ("a" LIKE ? AND ("b" BETWEEN ? AND ? OR NOT ("c" ILIKE ?))))`, []interface{}{"x%", 1, 2, "y"}},
+	}
+
+	defer func(d Dialect) { dialect = d }(dialect)
+	dialect = postgresDialect{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := buildFilter(tt.where, whereColumn(nil))
+			if err != nil {
+				t.Fatalf("buildFilter: %v", err)
+			}
+			sql, args, err := filter.ToSql()
+			if err != nil {
+				t.Fatalf("ToSql: %v", err)
+			}
+			if sql != tt.sql {
+				t.Errorf("sql = %s, want %s", sql, tt.sql)
+			}
+			if len(args) != 0 || len(tt.args) != 0 {
+				if !reflect.DeepEqual(args, tt.args) {
+					t.Errorf("args = %v, want %v", args, tt.args)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildFilterErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		where []WhereClause
+		err   string
+	}{
+		{"unknown operator", []WhereClause{{Column: "a", Op: "= 1 OR 1 =", Value: 1}}, "unsupported operator"},
+		{"operator injection", []WhereClause{{Column: "a", Op: "; DROP TABLE t", Value: 1}}, "unsupported operator"},
+		{"missing operator", []WhereClause{{Column: "a", Value: 1}}, "unsupported operator"},
+		{"in without array", []WhereClause{{Column: "a", Op: "IN", Value: 1}}, "requires an array value"},
+		{"between with one value", []WhereClause{{Column: "a", Op: "BETWEEN", Value: []interface{}{1}}}, "requires an array of two values"},
+		{"array for single value", []WhereClause{{Column: "a", Op: "=", Value: []interface{}{1, 2}}}, "requires a single value"},
+		{"object for single value", []WhereClause{{Column: "a", Op: "=", Value: map[string]interface{}{"x": 1}}}, "requires a single value"},
+		{"empty condition", []WhereClause{{}}, "exactly one of column, and, or, not"},
+		{"column and group", []WhereClause{{Column: "a", Op: "=", Value: 1, Or: []WhereClause{{Column: "b", Op: "=", Value: 2}}}}, "exactly one of column, and, or, not"},
+		{"empty or group", []WhereClause{{Or: []WhereClause{}}}, "or group must contain at least one condition"},
+		{"empty not group", []WhereClause{{Not: []WhereClause{}}}, "not group must contain at least one condition"},
+		{"error in nested group", []WhereClause{{Not: []WhereClause{{Or: []WhereClause{{Column: "a", Op: "MATCHES", Value: 1}}}}}}, "unsupported operator"},
+		{"empty column part", []WhereClause{{Column: "t.", Op: "=", Value: 1}}, "invalid WHERE column"},
+	}
+
+	defer func(d Dialect) { dialect = d }(dialect)
+	dialect = postgresDialect{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := buildFilter(tt.where, whereColumn(nil))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("buildFilter error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestWhereConditionILike(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		op      string
+		sql     string
+	}{
+		{postgresDialect{}, "ILIKE", `"a" ILIKE ?`},
+		{postgresDialect{}, "NOT ILIKE", `NOT ("a" ILIKE ?)`},
+		{mysqlDialect{}, "ILIKE", "LOWER(`a`) LIKE LOWER(?)"},
+		{sqliteDialect{}, "ILIKE", `LOWER("a") LIKE LOWER(?)`},
+	}
+
+	defer func(d Dialect) { dialect = d }(dialect)
+	for _, tt := range tests {
+		dialect = tt.dialect
+		col, _ := quoteIdentifier("a")
+		condition, err := whereCondition(col, WhereClause{Column: "a", Op: tt.op, Value: "x"})
+		if err != nil {
+			t.Fatalf("%s %s: %v", tt.dialect.Name(), tt.op, err)
+		}
+		sql, _, err := condition.ToSql()
+		if err != nil {
+			t.Fatalf("%s %s: %v", tt.dialect.Name(), tt.op, err)
+		}
+		if sql != tt.sql {
+			t.Errorf("%s %s = %s, want %s", tt.dialect.Name(), tt.op, sql, tt.sql)
+		}
+	}
+}
@@ -2,143 +2,58 @@ package main
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"strings"
-
-	sq "github.com/Masterminds/squirrel"
+	"unicode"
 )
 
-func sanitizeIdentifier(identifier string) string {
-	// Basic identifier validation - allow alphanumeric, underscore, dot, and space (for ORDER BY DESC/ASC)
-	// This prevents SQL injection in identifiers
+// quoteIdentifier quotes a column or table reference for the active dialect.
+// Dotted references such as "orders.id" are quoted part by part, so
+// mixed-case, hyphenated and Unicode names are preserved exactly. Empty parts
+// and control characters are rejected with an error.
+func quoteIdentifier(identifier string) (string, error) {
 	identifier = strings.TrimSpace(identifier)
-	for _, char := range identifier {
-		if !(char >= 'a' && char <= 'z') &&
-			!(char >= 'A' && char <= 'Z') &&
-			!(char >= '0' && char <= '9') &&
-			char != '_' && char != ' ' && char != '.' {
-			return ""
-		}
+	if identifier == "" {
+		return "", fmt.Errorf("identifier must not be empty")
 	}
-	return identifier
-}
 
-func applyWhereConditions(query sq.SelectBuilder, clauses []WhereClause) sq.SelectBuilder {
-	for _, clause := range clauses {
-		col := sanitizeIdentifier(clause.Column)
-		if col == "" {
-			continue
-		}
-		op := strings.ToUpper(clause.Op)
-
-		switch op {
-		case "=":
-			query = query.Where(sq.Eq{col: clause.Value})
-		case "!=", "<>":
-			query = query.Where(sq.NotEq{col: clause.Value})
-		case ">":
-			query = query.Where(sq.Gt{col: clause.Value})
-		case ">=":
-			query = query.Where(sq.GtOrEq{col: clause.Value})
-		case "<":
-			query = query.Where(sq.Lt{col: clause.Value})
-		case "<=":
-			query = query.Where(sq.LtOrEq{col: clause.Value})
-		case "LIKE", "ILIKE":
-			query = query.Where(sq.Like{col: clause.Value})
-		case "IN":
-			query = query.Where(sq.Eq{col: clause.Value})
-		case "NOT IN":
-			query = query.Where(sq.NotEq{col: clause.Value})
-		case "IS NULL":
-			query = query.Where(sq.Eq{col: nil})
-		case "IS NOT NULL":
-			query = query.Where(sq.NotEq{col: nil})
-		case "BETWEEN":
-			// BETWEEN expects a slice/array with 2 values
-			query = query.Where(sq.Expr(col+" BETWEEN ? AND ?", clause.Value))
-		default:
-			// For operators not directly supported, use Expr (still parameterized)
-			query = query.Where(sq.Expr(col+" "+op+" ?", clause.Value))
+	parts := strings.Split(identifier, ".")
+	for i, part := range parts {
+		if part == "" {
+			return "", fmt.Errorf("invalid identifier %q: empty name part", identifier)
 		}
+		for _, char := range part {
+			if unicode.IsControl(char) {
+				return "", fmt.Errorf("invalid identifier %q: contains control characters", identifier)
+			}
+		}
+		parts[i] = dialect.QuoteIdentifier(part)
 	}
-	return query
+	return strings.Join(parts, "."), nil
 }
 
-func applyWhereConditionsUpdate(query sq.UpdateBuilder, clauses []WhereClause) sq.UpdateBuilder {
-	for _, clause := range clauses {
-		col := sanitizeIdentifier(clause.Column)
-		if col == "" {
-			continue
-		}
-		op := strings.ToUpper(clause.Op)
-
-		switch op {
-		case "=":
-			query = query.Where(sq.Eq{col: clause.Value})
-		case "!=", "<>":
-			query = query.Where(sq.NotEq{col: clause.Value})
-		case ">":
-			query = query.Where(sq.Gt{col: clause.Value})
-		case ">=":
-			query = query.Where(sq.GtOrEq{col: clause.Value})
-		case "<":
-			query = query.Where(sq.Lt{col: clause.Value})
-		case "<=":
-			query = query.Where(sq.LtOrEq{col: clause.Value})
-		case "LIKE", "ILIKE":
-			query = query.Where(sq.Like{col: clause.Value})
-		case "IN":
-			query = query.Where(sq.Eq{col: clause.Value})
-		case "NOT IN":
-			query = query.Where(sq.NotEq{col: clause.Value})
-		case "IS NULL":
-			query = query.Where(sq.Eq{col: nil})
-		case "IS NOT NULL":
-			query = query.Where(sq.NotEq{col: nil})
-		default:
-			query = query.Where(sq.Expr(col+" "+op+" ?", clause.Value))
-		}
+// quoteOrderBy quotes an ORDER BY entry of the form "column [ASC|DESC]".
+func quoteOrderBy(order string) (string, error) {
+	order, direction := splitOrderBy(order)
+	col, err := quoteIdentifier(order)
+	if err != nil {
+		return "", err
 	}
-	return query
+	return col + direction, nil
 }
 
-func applyWhereConditionsDelete(query sq.DeleteBuilder, clauses []WhereClause) sq.DeleteBuilder {
-	for _, clause := range clauses {
-		col := sanitizeIdentifier(clause.Column)
-		if col == "" {
-			continue
-		}
-		op := strings.ToUpper(clause.Op)
-
-		switch op {
-		case "=":
-			query = query.Where(sq.Eq{col: clause.Value})
-		case "!=", "<>":
-			query = query.Where(sq.NotEq{col: clause.Value})
-		case ">":
-			query = query.Where(sq.Gt{col: clause.Value})
-		case ">=":
-			query = query.Where(sq.GtOrEq{col: clause.Value})
-		case "<":
-			query = query.Where(sq.Lt{col: clause.Value})
-		case "<=":
-			query = query.Where(sq.LtOrEq{col: clause.Value})
-		case "LIKE", "ILIKE":
-			query = query.Where(sq.Like{col: clause.Value})
-		case "IN":
-			query = query.Where(sq.Eq{col: clause.Value})
-		case "NOT IN":
-			query = query.Where(sq.NotEq{col: clause.Value})
-		case "IS NULL":
-			query = query.Where(sq.Eq{col: nil})
-		case "IS NOT NULL":
-			query = query.Where(sq.NotEq{col: nil})
-		default:
-			query = query.Where(sq.Expr(col+" "+op+" ?", clause.Value))
+// splitOrderBy splits an ORDER BY entry into its column and its direction
+// (" ASC", " DESC" or empty).
+func splitOrderBy(order string) (string, string) {
+	order = strings.TrimSpace(order)
+	if idx := strings.LastIndex(order, " "); idx >= 0 {
+		switch strings.ToUpper(order[idx+1:]) {
+		case "ASC", "DESC":
+			return order[:idx], " " + strings.ToUpper(order[idx+1:])
 		}
 	}
-	return query
+	return order, ""
 }
 
 func scanRows(rows *sql.Rows) ([]map[string]interface{}, error) {
@@ -222,7 +137,289 @@ func formatResults(rows []map[string]interface{}, title string) string {
 	return result.String()
 }
 
-func formatTextResult(text string) string {
-	return text
+func formatDryRun(title string, preview *DryRunOutput) string {
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("✓ DRY RUN: %s (nothing was changed)\n\n", title))
+	output.WriteString(fmt.Sprintf("SQL: %s\n", preview.SQL))
+	if len(preview.Args) > 0 {
+		args, _ := json.Marshal(preview.Args)
+		output.WriteString(fmt.Sprintf("Args: %s\n", args))
+	}
+
+	if preview.Before != nil {
+		output.WriteString(fmt.Sprintf("\nWould affect %d row(s)\n\n", preview.Rows))
+		if preview.ExceedsLimit {
+			output.WriteString("⚠️  This exceeds the maximum row limit, so the change would be refused\n\n")
+		}
+		output.WriteString(formatResults(preview.Before, "Before"))
+		if preview.After != nil {
+			output.WriteString("\n\n" + formatResults(preview.After, "After"))
+		}
+	} else if preview.After != nil {
+		output.WriteString(fmt.Sprintf("\nWould insert %d row(s)", preview.Rows-preview.Updated-preview.Unchanged))
+		if preview.Updated > 0 {
+			output.WriteString(fmt.Sprintf(" and update %d existing row(s)", preview.Updated))
+		}
+		if preview.Unchanged > 0 {
+			output.WriteString(fmt.Sprintf(" and leave %d existing row(s) unchanged", preview.Unchanged))
+		}
+		output.WriteString("\n\n")
+		output.WriteString(formatResults(preview.After, "Rows"))
+	} else {
+		output.WriteString("\nAffected rows are not counted for raw SQL; use explain_query for the planner's estimate\n")
+	}
+	return output.String()
+}
+
+func formatExplain(output *ExplainOutput) string {
+	var result strings.Builder
+	title := "EXPLAIN"
+	if output.Analyzed {
+		title = "EXPLAIN ANALYZE"
+	}
+	result.WriteString(fmt.Sprintf("✓ %s on %s\n\n", title, output.Database))
+	result.WriteString(fmt.Sprintf("SQL: %s\n", output.SQL))
+	if len(output.Args) > 0 {
+		args, _ := json.Marshal(output.Args)
+		result.WriteString(fmt.Sprintf("Args: %s\n", args))
+	}
+
+	result.WriteString(formatPlanSummary(output.Summary))
+
+	plan, _ := json.MarshalIndent(output.Plan, "", "  ")
+	result.WriteString(fmt.Sprintf("\nPlan:\n```json\n%s\n```\n", plan))
+	if output.Analysis != "" {
+		result.WriteString(fmt.Sprintf("\nAnalysis:\n```\n%s\n```\n", output.Analysis))
+	}
+	return result.String()
+}
+
+// formatPlanSummary lists the estimates, full scans and hints of a plan.
+func formatPlanSummary(summary PlanSummary) string {
+	var result strings.Builder
+	var figures []string
+	if summary.TotalCost > 0 {
+		figures = append(figures, fmt.Sprintf("Estimated cost: %.2f", summary.TotalCost))
+	}
+	if summary.EstimatedRows > 0 {
+		figures = append(figures, fmt.Sprintf("Estimated rows: %d", summary.EstimatedRows))
+	}
+	if summary.RowsScanned > 0 {
+		figures = append(figures, fmt.Sprintf("Estimated rows scanned: %d", summary.RowsScanned))
+	}
+	if summary.ExecutionMS > 0 {
+		figures = append(figures, fmt.Sprintf("Execution time: %.3f ms", summary.ExecutionMS))
+	}
+	if len(figures) > 0 {
+		result.WriteString("\n" + strings.Join(figures, "\n") + "\n")
+	}
+
+	if len(summary.SeqScans) > 0 {
+		result.WriteString("\nFull table scans:\n")
+		for _, scan := range summary.SeqScans {
+			result.WriteString("- " + scan.Table)
+			if scan.Rows > 0 {
+				result.WriteString(fmt.Sprintf(" (~%d rows)", scan.Rows))
+			}
+			if scan.Filter != "" {
+				result.WriteString(" filter: " + scan.Filter)
+			}
+			result.WriteString("\n")
+		}
+	}
+	if len(summary.Hints) > 0 {
+		result.WriteString("\nHints:\n")
+		for _, hint := range summary.Hints {
+			result.WriteString("- " + hint + "\n")
+		}
+	}
+	return result.String()
+}
+
+func qualifiedName(database, schema string) string {
+	if schema == "" {
+		return database
+	}
+	return database + "." + schema
+}
+
+func formatTableSchema(schema *SchemaOutput) string {
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("Table: %s.%s\n\n", qualifiedName(schema.Database, schema.Schema), schema.Table))
+
+	// Only show the Key and Extra columns when the engine reports them
+	showKey, showExtra := false, false
+	for _, col := range schema.Columns {
+		showKey = showKey || col.PrimaryKey || col.Key != ""
+		showExtra = showExtra || col.Extra != ""
+	}
+
+	header := fmt.Sprintf("%-20s %-20s %-10s %-15s", "Column", "Type", "Nullable", "Default")
+	if showKey {
+		header += fmt.Sprintf(" %-10s", "Key")
+	}
+	if showExtra {
+		header += fmt.Sprintf(" %-15s", "Extra")
+	}
+	output.WriteString("Columns:\n")
+	output.WriteString(header + "\n")
+	output.WriteString(strings.Repeat("-", len(header)) + "\n")
+
+	for _, col := range schema.Columns {
+		nullable := "NO"
+		if col.Nullable {
+			nullable = "YES"
+		}
+		line := fmt.Sprintf("%-20s %-20s %-10s %-15s", col.Name, col.Type, nullable, col.Default)
+		if showKey {
+			key := col.Key
+			if key == "" && col.PrimaryKey {
+				key = "PRI"
+			}
+			line += fmt.Sprintf(" %-10s", key)
+		}
+		if showExtra {
+			line += fmt.Sprintf(" %-15s", col.Extra)
+		}
+		output.WriteString(line + "\n")
+	}
+
+	if len(schema.ForeignKeys) > 0 {
+		output.WriteString("\nForeign Keys:\n")
+		for _, fk := range schema.ForeignKeys {
+			output.WriteString(fmt.Sprintf("• %s → %s.%s(%s)\n", fk.Column, fk.ForeignSchema, fk.ForeignTable, fk.ForeignColumn))
+		}
+	}
+
+	if len(schema.Indexes) > 0 {
+		output.WriteString("\nIndexes:\n")
+		for _, idx := range schema.Indexes {
+			indexType := "INDEX"
+			if idx.Unique {
+				indexType = "UNIQUE"
+			}
+			output.WriteString(fmt.Sprintf("• %s (%s)\n", idx.Name, indexType))
+		}
+	}
+
+	return output.String()
+}
+
+func formatSequences(sequences *SequencesOutput) string {
+	result := fmt.Sprintf("Sequences in %s:\n\n", qualifiedName(sequences.Database, sequences.Schema))
+	if len(sequences.Sequences) == 0 {
+		return result + "No sequences found"
+	}
+
+	for _, seq := range sequences.Sequences {
+		result += fmt.Sprintf("• %s\n", seq.Name)
+		if seq.Type != "" {
+			result += fmt.Sprintf("  Type: %s\n", seq.Type)
+		}
+		if seq.Start != "" {
+			result += fmt.Sprintf("  Start: %s, Min: %s, Max: %s, Increment: %s\n", seq.Start, seq.Min, seq.Max, seq.Increment)
+		}
+		if seq.Current != nil {
+			result += fmt.Sprintf("  Current: %d\n", *seq.Current)
+		}
+		result += "\n"
+	}
+	return result
+}
+
+func formatCustomTypes(types *CustomTypesOutput) string {
+	if types.Message != "" {
+		return types.Message
+	}
+
+	result := fmt.Sprintf("Custom types in %s:\n\n", qualifiedName(types.Database, types.Schema))
+	if len(types.Types) == 0 {
+		return result + "No custom types found"
+	}
+
+	for _, typ := range types.Types {
+		result += fmt.Sprintf("• %s (%s)\n", typ.Name, typ.Category)
+		if typ.Category == "enum" {
+			result += fmt.Sprintf("  Values: %v\n", typ.Values)
+		}
+		result += "\n"
+	}
+	return result
 }
 
+func formatFunctions(functions *FunctionsOutput) string {
+	if functions.Message != "" {
+		return functions.Message
+	}
+
+	result := fmt.Sprintf("Functions and procedures in %s:\n\n", qualifiedName(functions.Database, functions.Schema))
+	if len(functions.Functions) == 0 {
+		return result + "No functions or procedures found"
+	}
+
+	// Group by kind
+	funcs := []FunctionInfo{}
+	procs := []FunctionInfo{}
+	others := []FunctionInfo{}
+
+	for _, f := range functions.Functions {
+		switch f.Kind {
+		case "function":
+			funcs = append(funcs, f)
+		case "procedure":
+			procs = append(procs, f)
+		default:
+			others = append(others, f)
+		}
+	}
+
+	describe := func(f FunctionInfo, withReturn bool) string {
+		var details []string
+		if withReturn && f.ReturnType != "" {
+			details = append(details, "Returns: "+f.ReturnType)
+		}
+		if f.Language != "" {
+			details = append(details, "Language: "+f.Language)
+		}
+		if f.Created != "" {
+			details = append(details, "Created: "+f.Created)
+		}
+		line := fmt.Sprintf("• %s(%s)\n", f.Name, f.Arguments)
+		if len(details) > 0 {
+			line += "  " + strings.Join(details, " | ") + "\n"
+		}
+		return line
+	}
+
+	if len(funcs) > 0 {
+		result += fmt.Sprintf("Functions (%d):\n", len(funcs))
+		for _, f := range funcs {
+			result += describe(f, true)
+		}
+		result += "\n"
+	}
+
+	if len(procs) > 0 {
+		result += fmt.Sprintf("Procedures (%d):\n", len(procs))
+		for _, p := range procs {
+			result += describe(p, false)
+		}
+		result += "\n"
+	}
+
+	if len(others) > 0 {
+		result += fmt.Sprintf("Other (%d):\n", len(others))
+		for _, o := range others {
+			result += fmt.Sprintf("• %s (%s)\n", o.Name, o.Kind)
+		}
+	}
+
+	return result
+}
+
+func formatFunctionSource(source *FunctionSourceOutput) string {
+	if source.Definition == "" {
+		return source.Message
+	}
+	return fmt.Sprintf("%s: %s.%s\n\n%s", strings.ToUpper(source.Kind), source.Schema, source.Name, source.Definition)
+}
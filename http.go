@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/auth"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// runHTTP serves the MCP streamable HTTP protocol on /mcp and the legacy
+// SSE protocol on /sse until ctx is cancelled, then shuts down gracefully.
+// Each client gets its own MCP session; when authToken is set, every request
+// must carry it as a bearer token before it can reach a tool.
+func runHTTP(ctx context.Context, server *mcp.Server, addr, authToken string) error {
+	getServer := func(*http.Request) *mcp.Server { return server }
+
+	mux := http.NewServeMux()
+	mux.Handle("/mcp", mcp.NewStreamableHTTPHandler(getServer, nil))
+	mux.Handle("/sse", mcp.NewSSEHandler(getServer, nil))
+
+	var handler http.Handler = mux
+	if authToken != "" {
+		handler = auth.RequireBearerToken(staticTokenVerifier(authToken), nil)(handler)
+	} else {
+		log.Printf("Warning: MCP_AUTH_TOKEN is not set, the HTTP transport accepts unauthenticated requests")
+	}
+
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		log.Printf("Listening on %s (streamable HTTP: /mcp, SSE: /sse)", addr)
+		errCh <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("http server failed: %w", err)
+	case <-ctx.Done():
+	}
+
+	log.Printf("Shutting down HTTP server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Close open MCP sessions so long-lived SSE streams don't block shutdown
+	for session := range server.Sessions() {
+		session.Close()
+	}
+
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("http server shutdown failed: %w", err)
+	}
+	if err := <-errCh; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// staticTokenVerifier accepts exactly one pre-shared bearer token.
+func staticTokenVerifier(token string) auth.TokenVerifier {
+	return func(ctx context.Context, presented string, req *http.Request) (*auth.TokenInfo, error) {
+		if subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+			return nil, auth.ErrInvalidToken
+		}
+		// The SDK rejects tokens without an expiration, so report one per request
+		return &auth.TokenInfo{Expiration: time.Now().Add(time.Hour)}, nil
+	}
+}
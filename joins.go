@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// joinScope maps each table of a joined SELECT, by its alias or else its
+// name, to the table's columns so that column references can be validated
+// before the query runs.
+type joinScope struct {
+	refs    []string
+	columns map[string]map[string]bool
+}
+
+// add registers table under ref, looking up its columns.
+func (s *joinScope) add(ctx context.Context, pool *dbPool, database, schema, table, ref string) error {
+	if _, ok := s.columns[ref]; ok {
+		return fmt.Errorf("table reference '%s' is used twice; give each joined table a distinct alias", ref)
+	}
+
+	schemaInfo, err := dialect.TableSchema(ctx, pool.db, database, schema, table)
+	if err != nil {
+		return fmt.Errorf("failed to get schema of table '%s': %w", table, err)
+	}
+	if len(schemaInfo.Columns) == 0 {
+		return fmt.Errorf("table '%s' not found in database '%s'", table, database)
+	}
+
+	columns := make(map[string]bool, len(schemaInfo.Columns))
+	for _, col := range schemaInfo.Columns {
+		columns[col.Name] = true
+	}
+	s.refs = append(s.refs, ref)
+	s.columns[ref] = columns
+	return nil
+}
+
+// check validates a column reference: "alias.column", "alias.*", or a bare
+// column that exists in exactly one of the tables.
+func (s *joinScope) check(column string) error {
+	column = strings.TrimSpace(column)
+	if column == "*" {
+		return nil
+	}
+
+	if idx := strings.LastIndex(column, "."); idx >= 0 {
+		ref, name := column[:idx], column[idx+1:]
+		columns, ok := s.columns[ref]
+		if !ok {
+			return fmt.Errorf("unknown table reference '%s' in column '%s' (expected one of: %s)", ref, column, strings.Join(s.refs, ", "))
+		}
+		if name != "*" && !columns[name] {
+			return fmt.Errorf("column '%s' does not exist in '%s'", name, ref)
+		}
+		return nil
+	}
+
+	var found []string
+	for _, ref := range s.refs {
+		if s.columns[ref][column] {
+			found = append(found, ref+"."+column)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return fmt.Errorf("column '%s' does not exist in any joined table", column)
+	case 1:
+		return nil
+	default:
+		return fmt.Errorf("column '%s' is ambiguous; qualify it as one of: %s", column, strings.Join(found, ", "))
+	}
+}
+
+// buildJoins resolves the base table and the joins of input. It returns the
+// FROM item, one JOIN clause per join and the scope used to validate column
+// references.
+func buildJoins(ctx context.Context, pool *dbPool, input QuerySelectInput, tableName string) (string, []string, *joinScope, error) {
+	scope := &joinScope{columns: map[string]map[string]bool{}}
+
+	from, ref, err := aliasTable(tableName, input.Table, input.Alias)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if err := scope.add(ctx, pool, input.Database, input.Schema, input.Table, ref); err != nil {
+		return "", nil, nil, err
+	}
+
+	joins := make([]string, 0, len(input.Joins))
+	for _, join := range input.Joins {
+		joinType := strings.ToUpper(strings.TrimSpace(join.Type))
+		switch joinType {
+		case "":
+			joinType = "INNER"
+		case "INNER", "LEFT", "RIGHT":
+		case "FULL":
+			if dialect.Name() == "mysql" {
+				return "", nil, nil, fmt.Errorf("FULL joins are not supported on mysql")
+			}
+		default:
+			return "", nil, nil, fmt.Errorf("invalid join type '%s' (expected INNER, LEFT, RIGHT or FULL)", join.Type)
+		}
+
+		schema := join.Schema
+		if schema == "" {
+			schema = input.Schema
+		}
+		joinTable, err := dialect.QualifiedTable(ctx, pool.db, input.Database, schema, join.Table)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		item, ref, err := aliasTable(joinTable, join.Table, join.Alias)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		if err := scope.add(ctx, pool, input.Database, schema, join.Table, ref); err != nil {
+			return "", nil, nil, err
+		}
+
+		if len(join.On) == 0 {
+			return "", nil, nil, fmt.Errorf("join on '%s' requires at least one on condition", ref)
+		}
+		conditions := make([]string, len(join.On))
+		for i, on := range join.On {
+			left, err := scopedColumn(scope, on.Left)
+			if err != nil {
+				return "", nil, nil, fmt.Errorf("invalid join condition: %w", err)
+			}
+			right, err := scopedColumn(scope, on.Right)
+			if err != nil {
+				return "", nil, nil, fmt.Errorf("invalid join condition: %w", err)
+			}
+			conditions[i] = left + " = " + right
+		}
+
+		joins = append(joins, fmt.Sprintf("%s JOIN %s ON %s", joinType, item, strings.Join(conditions, " AND ")))
+	}
+
+	return from, joins, scope, nil
+}
+
+// aliasTable returns the FROM/JOIN item for a qualified table and the name
+// its columns are referenced by: the alias when given, else the table name.
+func aliasTable(tableName, table, alias string) (string, string, error) {
+	alias = strings.TrimSpace(alias)
+	if alias == "" {
+		return tableName, table, nil
+	}
+	if strings.Contains(alias, ".") {
+		return "", "", fmt.Errorf("invalid alias %q: must not contain '.'", alias)
+	}
+	quoted, err := quoteIdentifier(alias)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid alias: %w", err)
+	}
+	return tableName + " AS " + quoted, alias, nil
+}
+
+// scopedColumn validates a column reference against scope and quotes it.
+func scopedColumn(scope *joinScope, column string) (string, error) {
+	if err := scope.check(column); err != nil {
+		return "", err
+	}
+	return quoteIdentifier(column)
+}
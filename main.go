@@ -2,31 +2,64 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
+	"os/signal"
+	"syscall"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
 func main() {
-	// Initialize database connection
+	transport := flag.String("transport", getEnv("MCP_TRANSPORT", "stdio"), "Transport to serve: stdio or http")
+	addr := flag.String("addr", getEnv("MCP_HTTP_ADDR", ":8080"), "Listen address for the http transport")
+	flag.Parse()
+
+	// Initialize database connection pools
 	if err := initDatabase(); err != nil {
 		log.Fatalf("Database initialization failed: %v", err)
 	}
-	defer db.Close()
+	defer closePools()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	server := newServer()
+	log.Printf("Starting MCP SQL server with 15 tools over %s", *transport)
+
+	switch *transport {
+	case "stdio":
+		// Run the server over stdin/stdout
+		if err := server.Run(ctx, &mcp.StdioTransport{}); err != nil && ctx.Err() == nil {
+			log.Fatal(err)
+		}
+	case "http":
+		if err := runHTTP(ctx, server, *addr, getEnv("MCP_AUTH_TOKEN", "")); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		log.Fatalf("Unsupported transport: %s (expected stdio or http)", *transport)
+	}
+}
 
+// newServer creates the MCP server and registers every tool.
+func newServer() *mcp.Server {
 	// Create MCP server
 	server := mcp.NewServer(
 		&mcp.Implementation{
 			Name:    "mcp-go-sql-server",
 			Version: "v2.0.0",
 		},
-		nil,
+		&mcp.ServerOptions{
+			CompletionHandler: CompleteArgument,
+		},
 	)
 
 	// Register query tools
 	mcp.AddTool(server, &mcp.Tool{
-		Name: "query_select",
-		Description: `Execute a SELECT query on the database.
+		Name:        "query_select",
+		InputSchema: inputSchema[QuerySelectInput](),
+		Description: `Execute a SELECT query on the database. Set joins to read several tables, referencing columns as alias.column, and aggregates with group_by/having to count or sum.
 
 **Example usage:**
 ` + "```json" + `
@@ -44,12 +77,12 @@ func main() {
 }
 ` + "```" + `
 
-**Operators:** =, !=, <, <=, >, >=, LIKE, IN, BETWEEN, IS NULL, IS NOT NULL`,
+**Operators:** =, !=, <, <=, >, >=, LIKE, NOT LIKE, ILIKE, NOT ILIKE, IN, NOT IN, BETWEEN, NOT BETWEEN, IS NULL, IS NOT NULL`,
 	}, QuerySelect)
 
 	mcp.AddTool(server, &mcp.Tool{
 		Name: "query_insert",
-		Description: `Insert a single row into a table. Blocked in read-only mode.
+		Description: `Insert a row into a table, or an array of rows in batches within one transaction. Set on_conflict to update rows that already exist (upsert) and returning to get the inserted rows back, e.g. generated ids. Blocked in read-only mode. Set dry_run to preview the statement without executing it.
 
 **Example usage:**
 ` + "```json" + `
@@ -65,8 +98,9 @@ func main() {
 	}, QueryInsert)
 
 	mcp.AddTool(server, &mcp.Tool{
-		Name: "query_update",
-		Description: `Update rows in a table. WHERE clause is required. Blocked in read-only mode.
+		Name:        "query_update",
+		InputSchema: inputSchema[QueryUpdateInput](),
+		Description: `Update rows in a table. WHERE clause is required. Blocked in read-only mode. Set returning to get the updated rows back. Set dry_run to preview the statement, the number of matching rows and a before/after sample without executing it.
 
 **Example usage:**
 ` + "```json" + `
@@ -80,8 +114,9 @@ func main() {
 	}, QueryUpdate)
 
 	mcp.AddTool(server, &mcp.Tool{
-		Name: "query_delete",
-		Description: `Delete rows from a table. WHERE clause is required. Blocked in read-only mode.
+		Name:        "query_delete",
+		InputSchema: inputSchema[QueryDeleteInput](),
+		Description: `Delete rows from a table. WHERE clause is required. Blocked in read-only mode. Set returning to get the deleted rows back. Set dry_run to preview the statement, the number of matching rows and a sample without executing it.
 
 **Example usage:**
 ` + "```json" + `
@@ -95,7 +130,7 @@ func main() {
 
 	mcp.AddTool(server, &mcp.Tool{
 		Name: "query_raw",
-		Description: `⚠️  DANGEROUS: Execute raw SQL queries. Must be explicitly enabled.
+		Description: `⚠️  DANGEROUS: Execute raw SQL queries. Must be explicitly enabled. Set dry_run to return the query and parameters without executing it; affected rows are not counted for raw SQL, use explain_query for an estimate.
 
 **Example usage:**
 ` + "```json" + `
@@ -107,6 +142,41 @@ func main() {
 ` + "```",
 	}, QueryRaw)
 
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "query_transaction",
+		InputSchema: inputSchema[QueryTransactionInput](),
+		Description: `Run several SELECT/INSERT/UPDATE/DELETE operations atomically in one transaction. Each operation takes the same arguments as the matching query tool; all must target the same database. Commits only if every step succeeds (UPDATE/DELETE limits apply per step), otherwise rolls back.
+
+**Example usage:**
+` + "```json" + `
+{
+  "operations": [
+    {"insert": {"database": "mydb", "table": "orders", "data": {"user_id": 1, "total": 99.5}}},
+    {"update": {"database": "mydb", "table": "users", "data": {"last_order": "2024-01-01"}, "where": [{"column": "id", "op": "=", "value": 1}]}},
+    {"select": {"database": "mydb", "table": "orders", "where": [{"column": "user_id", "op": "=", "value": 1}]}}
+  ]
+}
+` + "```",
+	}, QueryTransaction)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "explain_query",
+		InputSchema: inputSchema[ExplainQueryInput](),
+		Description: `Show the execution plan of a query to find out why it is slow. Pass either select (the same arguments as query_select) or a raw query (requires ALLOW_RAW_QUERY). Returns the plan tree and a summary of full table scans, estimated rows and possible missing indexes. Set analyze to execute the statement for actual rows and timings; any changes are rolled back (PostgreSQL and MySQL).
+
+**Example usage:**
+` + "```json" + `
+{
+  "select": {
+    "database": "mydb",
+    "table": "orders",
+    "where": [{"column": "customer_id", "op": "=", "value": 42}],
+    "order_by": ["created_at DESC"]
+  }
+}
+` + "```",
+	}, ExplainQuery)
+
 	// Register metadata tools
 	mcp.AddTool(server, &mcp.Tool{
 		Name:        "get_databases",
@@ -209,12 +279,11 @@ func main() {
 ` + "```",
 	}, ExecuteFunction)
 
-	log.Printf("Starting MCP SQL server with 13 tools")
-
-	// Run the server over stdin/stdout
-	if err := server.Run(context.Background(), &mcp.StdioTransport{}); err != nil {
-		log.Fatal(err)
-	}
-}
+	// Register table and schema resources
+	registerResources(server)
 
+	// Register prompt templates
+	registerPrompts(server)
 
+	return server
+}
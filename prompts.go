@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+var databaseArgument = &mcp.PromptArgument{Name: "database", Description: "Database name", Required: true}
+var schemaArgument = &mcp.PromptArgument{Name: "schema", Description: "Schema name (PostgreSQL, SQLite)"}
+
+// registerPrompts adds prompt templates for common database tasks. Each
+// prompt is pre-filled with the relevant table schema or function source so
+// the assistant starts from accurate context.
+func registerPrompts(server *mcp.Server) {
+	server.AddPrompt(&mcp.Prompt{
+		Name:        "explain_table",
+		Title:       "Explain this table",
+		Description: "Explain the purpose, columns and relationships of a table",
+		Arguments: []*mcp.PromptArgument{
+			databaseArgument,
+			schemaArgument,
+			{Name: "table", Description: "Table name", Required: true},
+		},
+	}, ExplainTablePrompt)
+
+	server.AddPrompt(&mcp.Prompt{
+		Name:        "write_query",
+		Title:       "Write a query for…",
+		Description: "Write a query against one or more tables for a described task",
+		Arguments: []*mcp.PromptArgument{
+			databaseArgument,
+			schemaArgument,
+			{Name: "tables", Description: "Comma-separated table names", Required: true},
+			{Name: "task", Description: "What the query should return or change", Required: true},
+		},
+	}, WriteQueryPrompt)
+
+	server.AddPrompt(&mcp.Prompt{
+		Name:        "review_function",
+		Title:       "Review this function",
+		Description: "Review a function or stored procedure for correctness, performance and safety",
+		Arguments: []*mcp.PromptArgument{
+			databaseArgument,
+			schemaArgument,
+			{Name: "function", Description: "Function/procedure name", Required: true},
+		},
+	}, ReviewFunctionPrompt)
+
+	server.AddPrompt(&mcp.Prompt{
+		Name:        "investigate_slow_query",
+		Title:       "Investigate slow query",
+		Description: "Diagnose why a query is slow and suggest indexes or rewrites",
+		Arguments: []*mcp.PromptArgument{
+			databaseArgument,
+			schemaArgument,
+			{Name: "query", Description: "The slow SQL query", Required: true},
+			{Name: "tables", Description: "Comma-separated tables the query reads"},
+		},
+	}, InvestigateSlowQueryPrompt)
+}
+
+func ExplainTablePrompt(ctx context.Context, req *mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	args, err := promptArguments(req, "database", "table")
+	if err != nil {
+		return nil, err
+	}
+
+	schema, err := promptTableSchema(ctx, args["database"], args["schema"], args["table"])
+	if err != nil {
+		return nil, err
+	}
+
+	text := fmt.Sprintf(`Explain the table %s in the %s database.
+
+Describe what the table appears to store, the meaning of each column, its primary key, and how it relates to other tables through foreign keys. Point out anything unusual, such as nullable columns that look required or missing indexes on foreign keys.
+
+%s`, args["table"], args["database"], schema)
+
+	return promptResult(fmt.Sprintf("Explain table %s", args["table"]), text), nil
+}
+
+func WriteQueryPrompt(ctx context.Context, req *mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	args, err := promptArguments(req, "database", "tables", "task")
+	if err != nil {
+		return nil, err
+	}
+
+	schemas, err := promptTableSchemas(ctx, args["database"], args["schema"], args["tables"])
+	if err != nil {
+		return nil, err
+	}
+
+	text := fmt.Sprintf(`Write a %s query for the %s database that does the following:
+
+%s
+
+Only use the tables and columns listed below. Prefer the structured query_select, query_insert, query_update and query_delete tools over query_raw, and explain any assumptions you make.
+
+%s`, dialect.Name(), args["database"], args["task"], schemas)
+
+	return promptResult("Write a query", text), nil
+}
+
+func ReviewFunctionPrompt(ctx context.Context, req *mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	args, err := promptArguments(req, "database", "function")
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := getPool(args["database"])
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := dialect.FunctionSource(ctx, pool.db, args["database"], args["schema"], args["function"])
+	if err != nil {
+		return nil, err
+	}
+	if source.Definition == "" {
+		return nil, errors.New(source.Message)
+	}
+
+	text := fmt.Sprintf(`Review the following %s %s.
+
+Check it for correctness, edge cases (NULL handling, empty inputs), performance problems, security issues such as dynamic SQL built from parameters, and whether its volatility and permissions are appropriate. Suggest concrete improvements.
+
+%s`, dialect.Name(), source.Kind, formatFunctionSource(source))
+
+	return promptResult(fmt.Sprintf("Review %s %s", source.Kind, source.Name), text), nil
+}
+
+func InvestigateSlowQueryPrompt(ctx context.Context, req *mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	args, err := promptArguments(req, "database", "query")
+	if err != nil {
+		return nil, err
+	}
+
+	schemas := "No table schemas were provided; use get_table_schema to inspect the tables the query reads."
+	if args["tables"] != "" {
+		if schemas, err = promptTableSchemas(ctx, args["database"], args["schema"], args["tables"]); err != nil {
+			return nil, err
+		}
+	}
+
+	text := fmt.Sprintf("Investigate why this %s query against the %s database is slow:\n\n```sql\n%s\n```\n\n"+
+		"Identify likely full table scans, missing or unused indexes, expensive joins or sorts, and non-sargable predicates. "+
+		"Suggest indexes or rewrites and explain the expected impact of each.\n\n%s",
+		dialect.Name(), args["database"], args["query"], schemas)
+
+	return promptResult("Investigate slow query", text), nil
+}
+
+// promptArguments returns the prompt arguments after checking that every
+// required argument is present.
+func promptArguments(req *mcp.GetPromptRequest, required ...string) (map[string]string, error) {
+	args := req.Params.Arguments
+	if args == nil {
+		args = map[string]string{}
+	}
+	for _, name := range required {
+		if strings.TrimSpace(args[name]) == "" {
+			return nil, fmt.Errorf("missing required argument: %s", name)
+		}
+	}
+	return args, nil
+}
+
+func promptTableSchema(ctx context.Context, database, schemaName, table string) (string, error) {
+	pool, err := getPool(database)
+	if err != nil {
+		return "", err
+	}
+
+	schema, err := dialect.TableSchema(ctx, pool.db, database, schemaName, table)
+	if err != nil {
+		return "", fmt.Errorf("failed to get table schema: %w", err)
+	}
+	if len(schema.Columns) == 0 {
+		return "", fmt.Errorf("table '%s' not found in %s", table, qualifiedName(database, schema.Schema))
+	}
+	return formatTableSchema(schema), nil
+}
+
+func promptTableSchemas(ctx context.Context, database, schemaName, tables string) (string, error) {
+	var schemas []string
+	for _, table := range strings.Split(tables, ",") {
+		table = strings.TrimSpace(table)
+		if table == "" {
+			continue
+		}
+		schema, err := promptTableSchema(ctx, database, schemaName, table)
+		if err != nil {
+			return "", err
+		}
+		schemas = append(schemas, schema)
+	}
+	return strings.Join(schemas, "\n"), nil
+}
+
+func promptResult(description, text string) *mcp.GetPromptResult {
+	return &mcp.GetPromptResult{
+		Description: description,
+		Messages: []*mcp.PromptMessage{
+			{
+				Role:    "user",
+				Content: &mcp.TextContent{Text: text},
+			},
+		},
+	}
+}
@@ -2,46 +2,214 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 
+	sq "github.com/Masterminds/squirrel"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
-func QuerySelect(ctx context.Context, req *mcp.CallToolRequest, input QuerySelectInput) (*mcp.CallToolResult, struct{}, error) {
-	if err := validateDatabase(input.Database); err != nil {
-		return nil, struct{}{}, err
+// dryRunSampleRows is the number of affected rows shown by a dry run.
+const dryRunSampleRows = 10
+
+// insertBatchRows caps the rows in one multi-row INSERT, and maxInsertParams
+// its bound values, which stays under SQLite's default limit of 999.
+const (
+	insertBatchRows = 100
+	maxInsertParams = 999
+)
+
+// statement is a built SQL statement together with its bound arguments.
+type statement struct {
+	sql  string
+	args []interface{}
+}
+
+// insertBatch is one multi-row INSERT of data. For an upsert, existing counts
+// how many of those rows are already present and will be updated, or left
+// unchanged when the upsert has no columns to update.
+type insertBatch struct {
+	stmt      statement
+	data      []map[string]interface{}
+	existing  *statement
+	unchanged bool
+	returning *returning
+}
+
+// mutation is a built UPDATE or DELETE together with queries over the rows it
+// targets: a COUNT(*) used to enforce the row limit and a sample of the rows
+// shown by dry runs. When rows are returned without a RETURNING clause,
+// before selects the keys (UPDATE) or returned columns (DELETE) of the
+// matching rows ahead of the change.
+type mutation struct {
+	stmt      statement
+	count     statement
+	sample    statement
+	matched   sq.SelectBuilder
+	set       map[string]interface{}
+	returning *returning
+	before    statement
+}
+
+func QuerySelect(ctx context.Context, req *mcp.CallToolRequest, input QuerySelectInput) (*mcp.CallToolResult, QueryOutput, error) {
+	pool, err := getPool(input.Database)
+	if err != nil {
+		return nil, QueryOutput{}, err
+	}
+
+	stmt, err := buildSelect(ctx, pool, input)
+	if err != nil {
+		return nil, QueryOutput{}, err
+	}
+
+	var results []map[string]interface{}
+	err = withSession(ctx, pool.db, timeoutFor(input.TimeoutMS), func(ctx context.Context, q queryer) error {
+		if err := checkCost(ctx, q, input.Database, stmt); err != nil {
+			return err
+		}
+		results, err = runSelect(ctx, q, stmt)
+		return err
+	})
+	if err != nil {
+		return nil, QueryOutput{}, err
 	}
 
+	text := formatResults(results, fmt.Sprintf("SELECT from %s.%s", input.Database, input.Table))
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{
+				Text: text,
+			},
+		},
+	}, QueryOutput{Rows: results}, nil
+}
+
+func buildSelect(ctx context.Context, pool *dbPool, input QuerySelectInput) (statement, error) {
 	// Build fully qualified table name
-	tableName := input.Table
-	if dbType == "mysql" {
-		tableName = fmt.Sprintf("`%s`.`%s`", input.Database, input.Table)
+	tableName, err := dialect.QualifiedTable(ctx, pool.db, input.Database, input.Schema, input.Table)
+	if err != nil {
+		return statement{}, err
 	}
 
 	// Build SELECT query using Squirrel
-	query := qb.Select().From(tableName)
+	query := pool.qb.Select().From(tableName)
+
+	// Add joins; column references are then validated against the joined tables
+	var scope *joinScope
+	if len(input.Joins) > 0 {
+		from, joins, joinScope, err := buildJoins(ctx, pool, input, tableName)
+		if err != nil {
+			return statement{}, err
+		}
+		query = query.From(from)
+		for _, join := range joins {
+			query = query.JoinClause(join)
+		}
+		scope = joinScope
+	} else if input.Alias != "" {
+		from, _, err := aliasTable(tableName, input.Table, input.Alias)
+		if err != nil {
+			return statement{}, err
+		}
+		query = query.From(from)
+	}
+
+	aggs, err := buildAggregates(input.Aggregates, scope)
+	if err != nil {
+		return statement{}, err
+	}
 
-	// Add columns
-	if len(input.Columns) > 0 {
-		cols := make([]string, len(input.Columns))
+	// Add columns, followed by aggregates
+	if len(input.Columns) > 0 || len(aggs) > 0 {
+		cols := make([]string, len(input.Columns), len(input.Columns)+len(aggs))
 		for i, col := range input.Columns {
-			cols[i] = sanitizeIdentifier(col)
+			if scope != nil {
+				if err := scope.check(col); err != nil {
+					return statement{}, fmt.Errorf("invalid column: %w", err)
+				}
+			}
+			col = strings.TrimSpace(col)
+			if col == "*" {
+				cols[i] = "*"
+				continue
+			}
+			if ref, ok := strings.CutSuffix(col, ".*"); ok {
+				// All columns of one joined table
+				if cols[i], err = quoteIdentifier(ref); err != nil {
+					return statement{}, fmt.Errorf("invalid column: %w", err)
+				}
+				cols[i] += ".*"
+				continue
+			}
+			if cols[i], err = quoteIdentifier(col); err != nil {
+				return statement{}, fmt.Errorf("invalid column: %w", err)
+			}
+		}
+		for _, agg := range aggs {
+			cols = append(cols, agg.column())
 		}
 		query = query.Columns(cols...)
 	} else {
 		query = query.Columns("*")
 	}
 
+	if input.Distinct {
+		query = query.Distinct()
+	}
+
 	// Add WHERE conditions
 	if len(input.Where) > 0 {
-		query = applyWhereConditions(query, input.Where)
+		filter, err := buildFilter(input.Where, whereColumn(scope))
+		if err != nil {
+			return statement{}, err
+		}
+		query = query.Where(filter)
+	}
+	if scope != nil {
+		for _, order := range input.OrderBy {
+			column, _ := splitOrderBy(order)
+			if _, ok := findAggregate(aggs, column); ok {
+				continue
+			}
+			if err := scope.check(column); err != nil {
+				return statement{}, fmt.Errorf("invalid ORDER BY: %w", err)
+			}
+		}
+	}
+
+	// Add GROUP BY and HAVING
+	if len(input.GroupBy) > 0 {
+		groupBy := make([]string, len(input.GroupBy))
+		for i, col := range input.GroupBy {
+			if scope != nil {
+				if err := scope.check(col); err != nil {
+					return statement{}, fmt.Errorf("invalid GROUP BY: %w", err)
+				}
+			}
+			if groupBy[i], err = quoteIdentifier(col); err != nil {
+				return statement{}, fmt.Errorf("invalid GROUP BY: %w", err)
+			}
+		}
+		query = query.GroupBy(groupBy...)
+	}
+	if len(input.Having) > 0 {
+		if query, err = applyHavingConditions(query, input.Having, aggs, scope); err != nil {
+			return statement{}, err
+		}
 	}
 
 	// Add ORDER BY
 	if len(input.OrderBy) > 0 {
 		for _, order := range input.OrderBy {
-			query = query.OrderBy(sanitizeIdentifier(order))
+			orderBy, err := quoteOrderBy(order)
+			if err != nil {
+				return statement{}, fmt.Errorf("invalid ORDER BY: %w", err)
+			}
+			query = query.OrderBy(orderBy)
 		}
 	}
 
@@ -56,285 +224,1131 @@ func QuerySelect(ctx context.Context, req *mcp.CallToolRequest, input QuerySelec
 		limit = maxSelectLimit
 	}
 	query = query.Limit(uint64(limit))
-	
+
 	if input.Offset > 0 {
 		query = query.Offset(uint64(input.Offset))
 	}
 
-	// Execute query
 	sqlQuery, args, err := query.ToSql()
 	if err != nil {
-		return nil, struct{}{}, fmt.Errorf("failed to build query: %w", err)
+		return statement{}, fmt.Errorf("failed to build query: %w", err)
 	}
+	return statement{sql: sqlQuery, args: args}, nil
+}
 
-	rows, err := db.QueryContext(ctx, sqlQuery, args...)
+func runSelect(ctx context.Context, q queryer, stmt statement) ([]map[string]interface{}, error) {
+	rows, err := q.QueryContext(ctx, stmt.sql, stmt.args...)
 	if err != nil {
-		return nil, struct{}{}, fmt.Errorf("query failed: %w", err)
+		return nil, fmt.Errorf("query failed: %w", err)
 	}
 	defer rows.Close()
 
-	results, err := scanRows(rows)
+	return scanRows(rows)
+}
+
+func QueryInsert(ctx context.Context, req *mcp.CallToolRequest, input QueryInsertInput) (*mcp.CallToolResult, QueryOutput, error) {
+	if readOnly {
+		return nil, QueryOutput{}, fmt.Errorf("database is in read-only mode")
+	}
+
+	pool, err := getPool(input.Database)
 	if err != nil {
-		return nil, struct{}{}, err
+		return nil, QueryOutput{}, err
+	}
+
+	rows, err := insertRows(input.Data)
+	if err != nil {
+		return nil, QueryOutput{}, err
+	}
+
+	batches, err := buildInsert(ctx, pool, input, rows)
+	if err != nil {
+		return nil, QueryOutput{}, err
+	}
+
+	if input.DryRun {
+		preview := &DryRunOutput{
+			SQL:     batches[0].stmt.sql,
+			Args:    batches[0].stmt.args,
+			Rows:    int64(len(rows)),
+			After:   rows[:min(len(rows), dryRunSampleRows)],
+			Batches: len(batches),
+		}
+		if input.OnConflict != nil {
+			var existing int64
+			err := withSession(ctx, pool.db, timeoutFor(input.TimeoutMS), func(ctx context.Context, q queryer) error {
+				existing, err = countExisting(ctx, q, input.Database, batches)
+				return err
+			})
+			if err != nil {
+				return nil, QueryOutput{}, err
+			}
+			if batches[0].unchanged {
+				preview.Unchanged = existing
+			} else {
+				preview.Updated = existing
+			}
+		}
+		return dryRunResult(fmt.Sprintf("INSERT %s.%s", input.Database, input.Table), preview)
+	}
+
+	var inserted, updated, unchanged int64
+	var returned []map[string]interface{}
+	err = withConnTx(ctx, pool.db, timeoutFor(input.TimeoutMS), func(ctx context.Context, tx *sql.Tx) error {
+		inserted, updated, unchanged, returned, err = runInsert(ctx, tx, input.Database, batches)
+		return err
+	})
+	if err != nil {
+		return nil, QueryOutput{}, err
+	}
+
+	text := fmt.Sprintf("✓ INSERT successful\n\nInserted %d row(s) into %s.%s", inserted, input.Database, input.Table)
+	output := QueryOutput{Rows: returned, Affected: inserted, Message: "INSERT successful"}
+	if input.OnConflict != nil {
+		text = fmt.Sprintf("✓ UPSERT successful\n\nInserted %d and updated %d row(s) in %s.%s", inserted, updated, input.Database, input.Table)
+		if batches[0].unchanged {
+			text = fmt.Sprintf("✓ UPSERT successful\n\nInserted %d row(s) into %s.%s and left %d existing row(s) unchanged", inserted, input.Database, input.Table, unchanged)
+		}
+		output = QueryOutput{Rows: returned, Affected: inserted + updated, Inserted: inserted, Updated: updated, Unchanged: unchanged, Message: "UPSERT successful"}
+	}
+	if len(batches) > 1 {
+		text += fmt.Sprintf(" in %d batches", len(batches))
+	}
+	if len(input.Returning) > 0 {
+		text += "\n\n" + formatResults(returned, "Returned rows")
 	}
 
-	text := formatResults(results, fmt.Sprintf("SELECT from %s.%s", input.Database, input.Table))
-	
 	return &mcp.CallToolResult{
 		Content: []mcp.Content{
 			&mcp.TextContent{
 				Text: text,
 			},
 		},
-	}, struct{}{}, nil
+	}, output, nil
 }
 
-func QueryInsert(ctx context.Context, req *mcp.CallToolRequest, input QueryInsertInput) (*mcp.CallToolResult, struct{}, error) {
-	if readOnly {
-		return nil, struct{}{}, fmt.Errorf("database is in read-only mode")
+// insertRows normalizes the data argument of an insert, which is either one
+// object of column:value pairs or an array of them, and enforces
+// MAX_INSERT_ROWS.
+func insertRows(data interface{}) ([]map[string]interface{}, error) {
+	var rows []map[string]interface{}
+	switch v := data.(type) {
+	case map[string]interface{}:
+		rows = []map[string]interface{}{v}
+	case []interface{}:
+		for i, item := range v {
+			row, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("data[%d] must be an object of column:value pairs", i)
+			}
+			rows = append(rows, row)
+		}
+	default:
+		return nil, fmt.Errorf("data must be an object of column:value pairs or an array of them")
 	}
 
-	if err := validateDatabase(input.Database); err != nil {
-		return nil, struct{}{}, err
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("data must contain at least one row")
+	}
+	if len(rows) > maxInsertRows {
+		return nil, fmt.Errorf("INSERT has %d rows, which exceeds the maximum limit of %d. Please split the data into smaller inserts", len(rows), maxInsertRows)
 	}
+	return rows, nil
+}
 
+// buildInsert builds multi-row INSERT statements for rows. Every statement
+// uses the union of the rows' columns in sorted order, with NULL for columns a
+// row does not set, and holds at most insertBatchRows rows and
+// maxInsertParams bound values. With OnConflict set, each statement is an
+// upsert.
+func buildInsert(ctx context.Context, pool *dbPool, input QueryInsertInput, rows []map[string]interface{}) ([]insertBatch, error) {
 	// Build fully qualified table name
-	tableName := input.Table
-	if dbType == "mysql" {
-		tableName = fmt.Sprintf("`%s`.`%s`", input.Database, input.Table)
+	tableName, err := dialect.QualifiedTable(ctx, pool.db, input.Database, input.Schema, input.Table)
+	if err != nil {
+		return nil, err
 	}
 
-	// Build INSERT query using Squirrel
-	query := qb.Insert(tableName)
-
-	columns := make([]string, 0, len(input.Data))
-	values := make([]interface{}, 0, len(input.Data))
+	// Collect the union of columns across all rows
+	seen := make(map[string]bool)
+	var names []string
+	for _, row := range rows {
+		for col := range row {
+			if !seen[col] {
+				seen[col] = true
+				names = append(names, col)
+			}
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("data must contain at least one column")
+	}
+	sort.Strings(names)
 
-	for col, val := range input.Data {
-		columns = append(columns, sanitizeIdentifier(col))
-		values = append(values, val)
+	columns := make([]string, len(names))
+	for i, col := range names {
+		if columns[i], err = quoteIdentifier(col); err != nil {
+			return nil, fmt.Errorf("invalid column: %w", err)
+		}
 	}
 
-	query = query.Columns(columns...).Values(values...)
+	var upsert string
+	var target, keys []string
+	var unchanged bool
+	if input.OnConflict != nil {
+		if upsert, target, unchanged, err = buildUpsertClause(input.OnConflict, names); err != nil {
+			return nil, err
+		}
+		if dialect.Name() == "mysql" {
+			schemaInfo, err := dialect.TableSchema(ctx, pool.db, input.Database, input.Schema, input.Table)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get table schema: %w", err)
+			}
+			if err := checkUpsertTarget(schemaInfo, input.OnConflict.Columns, names); err != nil {
+				return nil, err
+			}
+		}
+		// The conflict target identifies upserted rows even when they
+		// were updated rather than inserted
+		keys = input.OnConflict.Columns
+	}
 
-	// Execute query
-	sqlQuery, args, err := query.ToSql()
+	r, err := buildReturning(ctx, pool, input.Database, input.Schema, input.Table, tableName, input.Returning, keys)
 	if err != nil {
-		return nil, struct{}{}, fmt.Errorf("failed to build query: %w", err)
+		return nil, err
 	}
 
-	result, err := db.ExecContext(ctx, sqlQuery, args...)
-	if err != nil {
-		return nil, struct{}{}, fmt.Errorf("insert failed: %w", err)
+	batchRows := max(1, min(insertBatchRows, maxInsertParams/len(columns)))
+	if r != nil && !r.native && !r.hasKeys(rows) {
+		// Generated ids are read back from LastInsertId, which is only
+		// exact for a single row
+		batchRows = 1
 	}
 
-	affected, _ := result.RowsAffected()
-	text := fmt.Sprintf("✓ INSERT successful\n\nInserted %d row(s) into %s.%s", affected, input.Database, input.Table)
-	
-	return &mcp.CallToolResult{
-		Content: []mcp.Content{
-			&mcp.TextContent{
-				Text: text,
-			},
-		},
-	}, struct{}{}, nil
+	var batches []insertBatch
+	for start := 0; start < len(rows); start += batchRows {
+		batch := rows[start:min(start+batchRows, len(rows))]
+
+		// Build INSERT query using Squirrel
+		query := pool.qb.Insert(tableName).Columns(columns...)
+		for _, row := range batch {
+			values := make([]interface{}, len(names))
+			for i, col := range names {
+				values[i] = row[col]
+			}
+			query = query.Values(values...)
+		}
+		if upsert != "" {
+			query = query.Suffix(upsert)
+		}
+		if r != nil && r.native {
+			query = query.Suffix(r.clause())
+		}
+
+		sqlQuery, args, err := query.ToSql()
+		if err != nil {
+			return nil, fmt.Errorf("failed to build query: %w", err)
+		}
+		b := insertBatch{stmt: statement{sql: sqlQuery, args: args}, data: batch, unchanged: unchanged, returning: r}
+
+		if upsert != "" {
+			existing, err := buildExisting(pool, tableName, input.OnConflict.Columns, target, batch)
+			if err != nil {
+				return nil, err
+			}
+			b.existing = &existing
+		}
+		batches = append(batches, b)
+	}
+	return batches, nil
 }
 
-func QueryUpdate(ctx context.Context, req *mcp.CallToolRequest, input QueryUpdateInput) (*mcp.CallToolResult, struct{}, error) {
-	if readOnly {
-		return nil, struct{}{}, fmt.Errorf("database is in read-only mode")
+// buildUpsertClause validates an on_conflict option against the inserted
+// columns and returns the dialect's upsert clause with the quoted conflict
+// target. Update columns default to every inserted column outside the target
+// when onConflict.Update is nil; an empty list updates nothing. unchanged
+// reports that there is nothing to update, so conflicting rows are left as
+// they are.
+func buildUpsertClause(onConflict *OnConflict, names []string) (clause string, target []string, unchanged bool, err error) {
+	if len(onConflict.Columns) == 0 {
+		return "", nil, false, fmt.Errorf("on_conflict.columns must name the primary key or unique columns")
 	}
 
-	if err := validateDatabase(input.Database); err != nil {
-		return nil, struct{}{}, err
+	inserted := make(map[string]bool, len(names))
+	for _, col := range names {
+		inserted[col] = true
 	}
 
-	if len(input.Where) == 0 {
-		return nil, struct{}{}, fmt.Errorf("WHERE clause is required for UPDATE")
+	target = make([]string, len(onConflict.Columns))
+	isTarget := make(map[string]bool, len(onConflict.Columns))
+	for i, col := range onConflict.Columns {
+		quoted, err := quoteIdentifier(col)
+		if err != nil {
+			return "", nil, false, fmt.Errorf("invalid on_conflict column: %w", err)
+		}
+		target[i] = quoted
+		isTarget[col] = true
 	}
 
-	// Build fully qualified table name
-	tableName := input.Table
-	if dbType == "mysql" {
-		tableName = fmt.Sprintf("`%s`.`%s`", input.Database, input.Table)
+	updateCols := onConflict.Update
+	if updateCols == nil {
+		for _, col := range names {
+			if !isTarget[col] {
+				updateCols = append(updateCols, col)
+			}
+		}
 	}
 
-	// Check row count before updating (enforce limit)
-	countQuery := qb.Select("COUNT(*)").From(tableName)
-	countQuery = applyWhereConditions(countQuery, input.Where)
-	countSQL, countArgs, err := countQuery.ToSql()
-	if err != nil {
-		return nil, struct{}{}, fmt.Errorf("failed to build count query: %w", err)
+	update := make([]string, len(updateCols))
+	for i, col := range updateCols {
+		if !inserted[col] {
+			return "", nil, false, fmt.Errorf("on_conflict.update column '%s' is not in data", col)
+		}
+		quoted, err := quoteIdentifier(col)
+		if err != nil {
+			return "", nil, false, fmt.Errorf("invalid on_conflict column: %w", err)
+		}
+		update[i] = quoted
 	}
 
-	var rowCount int
-	if err := db.QueryRowContext(ctx, countSQL, countArgs...).Scan(&rowCount); err != nil {
-		return nil, struct{}{}, fmt.Errorf("failed to check row count: %w", err)
+	return dialect.UpsertClause(target, update), target, len(update) == 0, nil
+}
+
+// checkUpsertTarget checks a MySQL upsert, whose inserted and updated rows are
+// told apart and read back by on_conflict columns. ON DUPLICATE KEY UPDATE
+// fires on any unique key, so columns must be exactly the primary key or one
+// unique index, and the inserted columns must not cover another unique key
+// that a row could collide on instead.
+func checkUpsertTarget(schema *SchemaOutput, columns, names []string) error {
+	var primary []string
+	for _, col := range schema.Columns {
+		if col.PrimaryKey {
+			primary = append(primary, col.Name)
+		}
+	}
+	keys := []IndexInfo{}
+	if len(primary) > 0 {
+		keys = append(keys, IndexInfo{Name: "PRIMARY", Columns: primary})
+	}
+	for _, idx := range schema.Indexes {
+		if idx.Unique {
+			keys = append(keys, idx)
+		}
 	}
 
-	if rowCount > maxUpdateLimit {
-		return nil, struct{}{}, fmt.Errorf("UPDATE would affect %d row(s), which exceeds the maximum limit of %d. Please refine your WHERE clause to target fewer rows", rowCount, maxUpdateLimit)
+	sameColumns := func(a, b []string) bool {
+		if len(a) != len(b) {
+			return false
+		}
+		set := make(map[string]bool, len(a))
+		for _, col := range a {
+			set[col] = true
+		}
+		for _, col := range b {
+			if !set[col] {
+				return false
+			}
+		}
+		return true
 	}
 
-	// Build UPDATE query using Squirrel
-	query := qb.Update(tableName)
+	inserted := make(map[string]bool, len(names))
+	for _, col := range names {
+		inserted[col] = true
+	}
 
-	// Add SET clauses
-	for col, val := range input.Data {
-		query = query.Set(sanitizeIdentifier(col), val)
+	found := false
+	for _, key := range keys {
+		if sameColumns(key.Columns, columns) {
+			found = true
+			continue
+		}
+		covered := true
+		for _, col := range key.Columns {
+			covered = covered && inserted[col]
+		}
+		if covered {
+			return fmt.Errorf("on_conflict.columns must be the only unique key set by data on mysql, but data also sets unique key %s (%s), where a conflict would update the row as well", key.Name, strings.Join(key.Columns, ", "))
+		}
+	}
+	if !found {
+		return fmt.Errorf("on_conflict.columns (%s) must be exactly the primary key or the columns of one unique index of table '%s' on mysql", strings.Join(columns, ", "), schema.Table)
 	}
+	return nil
+}
 
-	// Add WHERE conditions
-	query = applyWhereConditionsUpdate(query, input.Where)
+// buildExisting returns a COUNT(*) of the rows in batch whose conflict target
+// values are already present. Rows with a NULL in the target never conflict.
+func buildExisting(pool *dbPool, tableName string, names, target []string, batch []map[string]interface{}) (statement, error) {
+	keys := sq.Or{}
+	for _, row := range batch {
+		key := sq.Eq{}
+		for i, col := range names {
+			if row[col] == nil {
+				key = nil
+				break
+			}
+			key[target[i]] = row[col]
+		}
+		if key != nil {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		keys = append(keys, sq.Expr("1 = 0"))
+	}
 
-	// Execute query
-	sqlQuery, args, err := query.ToSql()
+	countSQL, countArgs, err := pool.qb.Select("COUNT(*)").From(tableName).Where(keys).ToSql()
 	if err != nil {
-		return nil, struct{}{}, fmt.Errorf("failed to build query: %w", err)
+		return statement{}, fmt.Errorf("failed to build count query: %w", err)
+	}
+	return statement{sql: countSQL, args: countArgs}, nil
+}
+
+// countExisting returns how many rows of an upsert already exist.
+func countExisting(ctx context.Context, q queryer, database string, batches []insertBatch) (int64, error) {
+	var total int64
+	for _, b := range batches {
+		if b.existing == nil {
+			continue
+		}
+		if err := checkCost(ctx, q, database, *b.existing); err != nil {
+			return 0, err
+		}
+		var existing int64
+		if err := q.QueryRowContext(ctx, b.existing.sql, b.existing.args...).Scan(&existing); err != nil {
+			return 0, fmt.Errorf("failed to check existing rows: %w", err)
+		}
+		total += existing
+	}
+	return total, nil
+}
+
+// runInsert runs the batches and returns the rows inserted and, for upserts,
+// the existing rows updated or left unchanged, plus the returned rows if
+// requested. Existing rows are counted just before each batch runs, inside
+// the same transaction.
+func runInsert(ctx context.Context, q queryer, database string, batches []insertBatch) (inserted, updated, unchanged int64, returned []map[string]interface{}, err error) {
+	for _, b := range batches {
+		existing, err := countExisting(ctx, q, database, []insertBatch{b})
+		if err != nil {
+			return 0, 0, 0, nil, err
+		}
+
+		var n int64
+		if b.returning != nil && b.returning.native {
+			rows, err := runReturning(ctx, q, "INSERT", b.stmt)
+			if err != nil {
+				return 0, 0, 0, nil, err
+			}
+			n = int64(len(rows))
+			returned = append(returned, rows...)
+		} else {
+			result, err := q.ExecContext(ctx, b.stmt.sql, b.stmt.args...)
+			if err != nil {
+				return 0, 0, 0, nil, fmt.Errorf("insert failed: %w", err)
+			}
+			n, _ = result.RowsAffected()
+
+			if b.returning != nil {
+				lastID, _ := result.LastInsertId()
+				rows, err := b.returning.selectInserted(ctx, q, b.data, lastID)
+				if err != nil {
+					return 0, 0, 0, nil, err
+				}
+				returned = append(returned, rows...)
+			}
+		}
+
+		if b.existing == nil {
+			inserted += n
+			continue
+		}
+		// RowsAffected is not comparable across engines for upserts
+		// (MySQL counts an updated row twice), so derive it from the count
+		inserted += int64(len(b.data)) - existing
+		if b.unchanged {
+			unchanged += existing
+		} else {
+			updated += existing
+		}
+	}
+	return inserted, updated, unchanged, returned, nil
+}
+
+func QueryUpdate(ctx context.Context, req *mcp.CallToolRequest, input QueryUpdateInput) (*mcp.CallToolResult, QueryOutput, error) {
+	if readOnly {
+		return nil, QueryOutput{}, fmt.Errorf("database is in read-only mode")
 	}
 
-	result, err := db.ExecContext(ctx, sqlQuery, args...)
+	pool, err := getPool(input.Database)
 	if err != nil {
-		return nil, struct{}{}, fmt.Errorf("update failed: %w", err)
+		return nil, QueryOutput{}, err
+	}
+
+	m, err := buildUpdate(ctx, pool, input)
+	if err != nil {
+		return nil, QueryOutput{}, err
+	}
+
+	if input.DryRun {
+		var preview *DryRunOutput
+		err := withSession(ctx, pool.db, timeoutFor(input.TimeoutMS), func(ctx context.Context, q queryer) error {
+			preview, err = previewMutation(ctx, q, input.Database, m, input.Data, maxUpdateLimit)
+			return err
+		})
+		if err != nil {
+			return nil, QueryOutput{}, err
+		}
+		return dryRunResult(fmt.Sprintf("UPDATE %s.%s", input.Database, input.Table), preview)
+	}
+
+	var affected int64
+	var returned []map[string]interface{}
+	err = withConnTx(ctx, pool.db, timeoutFor(input.TimeoutMS), func(ctx context.Context, tx *sql.Tx) error {
+		affected, returned, err = runLimited(ctx, tx, input.Database, "UPDATE", m, maxUpdateLimit)
+		return err
+	})
+	if err != nil {
+		return nil, QueryOutput{}, err
 	}
 
-	affected, _ := result.RowsAffected()
 	text := fmt.Sprintf("✓ UPDATE successful\n\nUpdated %d row(s) in %s.%s", affected, input.Database, input.Table)
-	
+	if len(input.Returning) > 0 {
+		text += "\n\n" + formatResults(returned, "Returned rows")
+	}
+
 	return &mcp.CallToolResult{
 		Content: []mcp.Content{
 			&mcp.TextContent{
 				Text: text,
 			},
 		},
-	}, struct{}{}, nil
+	}, QueryOutput{Rows: returned, Affected: affected, Message: "UPDATE successful"}, nil
 }
 
-func QueryDelete(ctx context.Context, req *mcp.CallToolRequest, input QueryDeleteInput) (*mcp.CallToolResult, struct{}, error) {
-	if readOnly {
-		return nil, struct{}{}, fmt.Errorf("database is in read-only mode")
+// buildUpdate returns the UPDATE statement together with the queries over
+// the rows it targets.
+func buildUpdate(ctx context.Context, pool *dbPool, input QueryUpdateInput) (mutation, error) {
+	if len(input.Where) == 0 {
+		return mutation{}, fmt.Errorf("WHERE clause is required for UPDATE")
 	}
 
-	if err := validateDatabase(input.Database); err != nil {
-		return nil, struct{}{}, err
+	// Build fully qualified table name
+	tableName, err := dialect.QualifiedTable(ctx, pool.db, input.Database, input.Schema, input.Table)
+	if err != nil {
+		return mutation{}, err
 	}
 
-	if len(input.Where) == 0 {
-		return nil, struct{}{}, fmt.Errorf("WHERE clause is required for DELETE")
+	m, err := buildMatch(pool, tableName, input.Where)
+	if err != nil {
+		return mutation{}, err
 	}
 
-	// Build fully qualified table name
-	tableName := input.Table
-	if dbType == "mysql" {
-		tableName = fmt.Sprintf("`%s`.`%s`", input.Database, input.Table)
+	// Build UPDATE query using Squirrel
+	query := pool.qb.Update(tableName)
+
+	// Add SET clauses
+	for col, val := range input.Data {
+		quoted, err := quoteIdentifier(col)
+		if err != nil {
+			return mutation{}, fmt.Errorf("invalid column: %w", err)
+		}
+		query = query.Set(quoted, val)
 	}
 
-	// Check row count before deleting (enforce limit)
-	countQuery := qb.Select("COUNT(*)").From(tableName)
-	countQuery = applyWhereConditions(countQuery, input.Where)
-	countSQL, countArgs, err := countQuery.ToSql()
+	// Add WHERE conditions
+	filter, err := buildFilter(input.Where, whereColumn(nil))
 	if err != nil {
-		return nil, struct{}{}, fmt.Errorf("failed to build count query: %w", err)
+		return mutation{}, err
 	}
+	query = query.Where(filter)
 
-	var rowCount int
-	if err := db.QueryRowContext(ctx, countSQL, countArgs...).Scan(&rowCount); err != nil {
-		return nil, struct{}{}, fmt.Errorf("failed to check row count: %w", err)
+	m.set = input.Data
+	if m.returning, err = buildReturning(ctx, pool, input.Database, input.Schema, input.Table, tableName, input.Returning, nil); err != nil {
+		return mutation{}, err
+	}
+	if m.returning != nil && m.returning.native {
+		query = query.Suffix(m.returning.clause())
+	} else if m.returning != nil {
+		if m.before, err = buildBefore(m, m.returning.quotedKeys); err != nil {
+			return mutation{}, err
+		}
 	}
 
-	if rowCount > maxDeleteLimit {
-		return nil, struct{}{}, fmt.Errorf("DELETE would affect %d row(s), which exceeds the maximum limit of %d. Please refine your WHERE clause to target fewer rows", rowCount, maxDeleteLimit)
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return mutation{}, fmt.Errorf("failed to build query: %w", err)
 	}
+	m.stmt = statement{sql: sqlQuery, args: args}
+	return m, nil
+}
 
-	// Build DELETE query using Squirrel
-	query := qb.Delete(tableName)
+func QueryDelete(ctx context.Context, req *mcp.CallToolRequest, input QueryDeleteInput) (*mcp.CallToolResult, QueryOutput, error) {
+	if readOnly {
+		return nil, QueryOutput{}, fmt.Errorf("database is in read-only mode")
+	}
 
-	// Add WHERE conditions
-	query = applyWhereConditionsDelete(query, input.Where)
+	pool, err := getPool(input.Database)
+	if err != nil {
+		return nil, QueryOutput{}, err
+	}
 
-	// Execute query
-	sqlQuery, args, err := query.ToSql()
+	m, err := buildDelete(ctx, pool, input)
 	if err != nil {
-		return nil, struct{}{}, fmt.Errorf("failed to build query: %w", err)
+		return nil, QueryOutput{}, err
+	}
+
+	if input.DryRun {
+		var preview *DryRunOutput
+		err := withSession(ctx, pool.db, timeoutFor(input.TimeoutMS), func(ctx context.Context, q queryer) error {
+			preview, err = previewMutation(ctx, q, input.Database, m, nil, maxDeleteLimit)
+			return err
+		})
+		if err != nil {
+			return nil, QueryOutput{}, err
+		}
+		return dryRunResult(fmt.Sprintf("DELETE %s.%s", input.Database, input.Table), preview)
 	}
 
-	result, err := db.ExecContext(ctx, sqlQuery, args...)
+	var affected int64
+	var returned []map[string]interface{}
+	err = withConnTx(ctx, pool.db, timeoutFor(input.TimeoutMS), func(ctx context.Context, tx *sql.Tx) error {
+		affected, returned, err = runLimited(ctx, tx, input.Database, "DELETE", m, maxDeleteLimit)
+		return err
+	})
 	if err != nil {
-		return nil, struct{}{}, fmt.Errorf("delete failed: %w", err)
+		return nil, QueryOutput{}, err
 	}
 
-	affected, _ := result.RowsAffected()
 	text := fmt.Sprintf("✓ DELETE successful\n\nDeleted %d row(s) from %s.%s", affected, input.Database, input.Table)
-	
+	if len(input.Returning) > 0 {
+		text += "\n\n" + formatResults(returned, "Returned rows")
+	}
+
 	return &mcp.CallToolResult{
 		Content: []mcp.Content{
 			&mcp.TextContent{
 				Text: text,
 			},
 		},
-	}, struct{}{}, nil
+	}, QueryOutput{Rows: returned, Affected: affected, Message: "DELETE successful"}, nil
 }
 
-func QueryRaw(ctx context.Context, req *mcp.CallToolRequest, input QueryRawInput) (*mcp.CallToolResult, struct{}, error) {
-	if !allowRawQuery {
-		return nil, struct{}{}, fmt.Errorf("raw SQL queries are blocked. Set ALLOW_RAW_QUERY=true to enable this dangerous feature")
+// buildDelete returns the DELETE statement together with the queries over
+// the rows it targets.
+func buildDelete(ctx context.Context, pool *dbPool, input QueryDeleteInput) (mutation, error) {
+	if len(input.Where) == 0 {
+		return mutation{}, fmt.Errorf("WHERE clause is required for DELETE")
 	}
 
-	if err := validateDatabase(input.Database); err != nil {
-		return nil, struct{}{}, err
+	// Build fully qualified table name
+	tableName, err := dialect.QualifiedTable(ctx, pool.db, input.Database, input.Schema, input.Table)
+	if err != nil {
+		return mutation{}, err
 	}
 
-	// Switch to the specified database for MySQL
-	if dbType == "mysql" {
-		_, err := db.ExecContext(ctx, fmt.Sprintf("USE `%s`", input.Database))
-		if err != nil {
-			return nil, struct{}{}, fmt.Errorf("failed to switch to database %s: %w", input.Database, err)
+	m, err := buildMatch(pool, tableName, input.Where)
+	if err != nil {
+		return mutation{}, err
+	}
+
+	// Build DELETE query using Squirrel
+	query := pool.qb.Delete(tableName)
+
+	// Add WHERE conditions
+	filter, err := buildFilter(input.Where, whereColumn(nil))
+	if err != nil {
+		return mutation{}, err
+	}
+	query = query.Where(filter)
+
+	if m.returning, err = buildReturning(ctx, pool, input.Database, input.Schema, input.Table, tableName, input.Returning, nil); err != nil {
+		return mutation{}, err
+	}
+	if m.returning != nil && m.returning.native {
+		query = query.Suffix(m.returning.clause())
+	} else if m.returning != nil {
+		if m.before, err = buildBefore(m, m.returning.columns); err != nil {
+			return mutation{}, err
 		}
 	}
 
-	isSelect := strings.HasPrefix(strings.ToUpper(strings.TrimSpace(input.Query)), "SELECT")
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return mutation{}, fmt.Errorf("failed to build query: %w", err)
+	}
+	m.stmt = statement{sql: sqlQuery, args: args}
+	return m, nil
+}
+
+// buildMatch returns a mutation with the COUNT(*) and sample of the rows
+// matching where filled in.
+func buildMatch(pool *dbPool, tableName string, where []WhereClause) (mutation, error) {
+	filter, err := buildFilter(where, whereColumn(nil))
+	if err != nil {
+		return mutation{}, err
+	}
+	matched := pool.qb.Select().From(tableName).Where(filter)
+
+	countSQL, countArgs, err := matched.Columns("COUNT(*)").ToSql()
+	if err != nil {
+		return mutation{}, fmt.Errorf("failed to build count query: %w", err)
+	}
+
+	sampleSQL, sampleArgs, err := matched.Columns("*").Limit(uint64(min(dryRunSampleRows, maxSelectLimit))).ToSql()
+	if err != nil {
+		return mutation{}, fmt.Errorf("failed to build sample query: %w", err)
+	}
+
+	return mutation{
+		count:   statement{sql: countSQL, args: countArgs},
+		sample:  statement{sql: sampleSQL, args: sampleArgs},
+		matched: matched,
+	}, nil
+}
+
+// buildBefore selects columns of the rows the mutation matches.
+func buildBefore(m mutation, columns []string) (statement, error) {
+	sqlQuery, args, err := m.matched.Columns(columns...).ToSql()
+	if err != nil {
+		return statement{}, fmt.Errorf("failed to build returning query: %w", err)
+	}
+	return statement{sql: sqlQuery, args: args}, nil
+}
+
+// countRows runs the mutation's COUNT(*), after the cost guard.
+func countRows(ctx context.Context, q queryer, database string, m mutation) (int64, error) {
+	if err := checkCost(ctx, q, database, m.count); err != nil {
+		return 0, err
+	}
+	var rowCount int64
+	if err := q.QueryRowContext(ctx, m.count.sql, m.count.args...).Scan(&rowCount); err != nil {
+		return 0, fmt.Errorf("failed to check row count: %w", err)
+	}
+	return rowCount, nil
+}
+
+// runLimited runs an UPDATE or DELETE after checking that the number of rows
+// it matches does not exceed limit. The count is only a fast pre-check: rows
+// can change between it and the statement, so the rows actually affected are
+// checked as well. q must be a transaction for that check to be enforceable;
+// the caller rolls back on error. The changed rows are returned when the
+// mutation requests them. The reads of the matching rows pass the cost guard.
+func runLimited(ctx context.Context, q queryer, database, verb string, m mutation, limit int) (int64, []map[string]interface{}, error) {
+	rowCount, err := countRows(ctx, q, database, m)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if rowCount > int64(limit) {
+		return 0, nil, fmt.Errorf("%s would affect %d row(s), which exceeds the maximum limit of %d. Please refine your WHERE clause to target fewer rows", verb, rowCount, limit)
+	}
+
+	r := m.returning
+	var before []map[string]interface{}
+	if r != nil && !r.native {
+		if err := checkCost(ctx, q, database, m.before); err != nil {
+			return 0, nil, err
+		}
+		if before, err = runSelect(ctx, q, m.before); err != nil {
+			return 0, nil, err
+		}
+	}
 
-	if isSelect {
-		rows, err := db.QueryContext(ctx, input.Query, input.Params...)
+	var affected int64
+	var returned []map[string]interface{}
+	if r != nil && r.native {
+		if returned, err = runReturning(ctx, q, verb, m.stmt); err != nil {
+			return 0, nil, err
+		}
+		affected = int64(len(returned))
+	} else {
+		result, err := q.ExecContext(ctx, m.stmt.sql, m.stmt.args...)
 		if err != nil {
-			return nil, struct{}{}, fmt.Errorf("query failed: %w", err)
+			return 0, nil, fmt.Errorf("%s failed: %w", strings.ToLower(verb), err)
+		}
+
+		if affected, err = result.RowsAffected(); err != nil {
+			return 0, nil, fmt.Errorf("failed to check affected rows: %w", err)
+		}
+	}
+	if affected > int64(limit) {
+		return 0, nil, fmt.Errorf("%s affected %d row(s), which exceeds the maximum limit of %d. The change was rolled back", verb, affected, limit)
+	}
+
+	if r != nil && !r.native {
+		if verb == "DELETE" {
+			returned = before
+		} else {
+			// Read the updated rows back by key, following any key
+			// columns the UPDATE itself changed
+			for _, row := range before {
+				for col, val := range m.set {
+					if _, isKey := row[col]; isKey {
+						row[col] = val
+					}
+				}
+			}
+			if returned, err = r.selectByKeys(ctx, q, before); err != nil {
+				return 0, nil, err
+			}
+		}
+	}
+	return affected, returned, nil
+}
+
+// runReturning runs a statement with a RETURNING clause and scans the rows it
+// returns.
+func runReturning(ctx context.Context, q queryer, verb string, stmt statement) ([]map[string]interface{}, error) {
+	rows, err := q.QueryContext(ctx, stmt.sql, stmt.args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s failed: %w", strings.ToLower(verb), err)
+	}
+	defer rows.Close()
+
+	results, err := scanRows(rows)
+	if err != nil {
+		return nil, err
+	}
+	if results == nil {
+		results = []map[string]interface{}{}
+	}
+	return results, nil
+}
+
+func QueryRaw(ctx context.Context, req *mcp.CallToolRequest, input QueryRawInput) (*mcp.CallToolResult, QueryOutput, error) {
+	if !allowRawQuery {
+		return nil, QueryOutput{}, fmt.Errorf("raw SQL queries are blocked. Set ALLOW_RAW_QUERY=true to enable this dangerous feature")
+	}
+
+	pool, err := getPool(input.Database)
+	if err != nil {
+		return nil, QueryOutput{}, err
+	}
+
+	statements, err := classifySQL(input.Query)
+	if err != nil {
+		return nil, QueryOutput{}, err
+	}
+	if len(statements) == 0 {
+		return nil, QueryOutput{}, fmt.Errorf("query contains no SQL statement")
+	}
+	if len(statements) > 1 {
+		if !allowMultiStatements {
+			return nil, QueryOutput{}, fmt.Errorf("query contains %d statements, but only one is allowed per call. Set ALLOW_MULTI_STATEMENTS=true to run several", len(statements))
+		}
+		if len(input.Params) > 0 {
+			return nil, QueryOutput{}, fmt.Errorf("params are not supported for queries with several statements")
+		}
+	}
+
+	if readOnly {
+		for _, stmt := range statements {
+			if !stmt.readOnly {
+				return nil, QueryOutput{}, fmt.Errorf("database is in read-only mode: %s statements are not allowed", stmt.verb)
+			}
 		}
-		defer rows.Close()
+	}
+
+	if input.DryRun {
+		// Raw SQL is not analyzed, so the affected rows are not counted;
+		// explain_query gives the planner's estimate instead
+		return dryRunResult("raw query", &DryRunOutput{SQL: input.Query, Args: input.Params})
+	}
 
-		results, err := scanRows(rows)
+	if len(statements) > 1 {
+		return runStatements(ctx, pool, input.Database, statements, timeoutFor(input.TimeoutMS))
+	}
+
+	if statements[0].returnsRows {
+		var results []map[string]interface{}
+		err := withSession(ctx, pool.db, timeoutFor(input.TimeoutMS), func(ctx context.Context, q queryer) error {
+			if costChecked(statements[0]) {
+				if err := checkCost(ctx, q, input.Database, statement{sql: statements[0].text, args: input.Params}); err != nil {
+					return err
+				}
+			}
+			results, err = runSelect(ctx, q, statement{sql: input.Query, args: input.Params})
+			return err
+		})
 		if err != nil {
-			return nil, struct{}{}, err
+			return nil, QueryOutput{}, err
 		}
 
 		text := formatResults(results, "Raw query successful")
-		
+
 		return &mcp.CallToolResult{
 			Content: []mcp.Content{
 				&mcp.TextContent{
 					Text: text,
 				},
 			},
-		}, struct{}{}, nil
-	}
-
-	if readOnly {
-		return nil, struct{}{}, fmt.Errorf("database is in read-only mode")
+		}, QueryOutput{Rows: results}, nil
 	}
 
-	result, err := db.ExecContext(ctx, input.Query, input.Params...)
+	var affected int64
+	err = withSession(ctx, pool.db, timeoutFor(input.TimeoutMS), func(ctx context.Context, q queryer) error {
+		result, err := q.ExecContext(ctx, input.Query, input.Params...)
+		if err != nil {
+			return fmt.Errorf("query failed: %w", err)
+		}
+		affected, _ = result.RowsAffected()
+		return nil
+	})
 	if err != nil {
-		return nil, struct{}{}, fmt.Errorf("query failed: %w", err)
+		return nil, QueryOutput{}, err
 	}
 
-	affected, _ := result.RowsAffected()
 	text := fmt.Sprintf("✓ Raw query successful\n\nAffected %d row(s)", affected)
-	
+
 	return &mcp.CallToolResult{
 		Content: []mcp.Content{
 			&mcp.TextContent{
 				Text: text,
 			},
 		},
-	}, struct{}{}, nil
+	}, QueryOutput{Affected: affected, Message: "Raw query successful"}, nil
+}
+
+// runStatements runs the statements of a raw query one at a time in a single
+// transaction, all within timeout. The rows of the last statement that
+// returns rows are the result; the affected counts of the others are summed.
+func runStatements(ctx context.Context, pool *dbPool, database string, statements []sqlStatement, timeout time.Duration) (*mcp.CallToolResult, QueryOutput, error) {
+	var text strings.Builder
+	output := QueryOutput{Message: "Raw query successful"}
+	err := withConnTx(ctx, pool.db, timeout, func(ctx context.Context, tx *sql.Tx) error {
+		return runEach(ctx, tx, database, statements, &output, &text)
+	})
+	if err != nil {
+		return nil, QueryOutput{}, err
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{
+				Text: fmt.Sprintf("✓ Raw query successful\n\nRan %d statement(s) in one transaction:\n", len(statements)) + text.String(),
+			},
+		},
+	}, output, nil
+}
+
+// runEach runs statements in tx, collecting their results into output and text.
+func runEach(ctx context.Context, tx *sql.Tx, database string, statements []sqlStatement, output *QueryOutput, text *strings.Builder) error {
+	for i, stmt := range statements {
+		title := fmt.Sprintf("%d. %s", i+1, stmt.verb)
+		if stmt.returnsRows {
+			// The cost is checked just before running, as the statement
+			// may read what earlier ones wrote
+			if costChecked(stmt) {
+				if err := checkCost(ctx, tx, database, statement{sql: stmt.text}); err != nil {
+					return fmt.Errorf("statement %d (%s) failed, transaction rolled back: %w", i+1, stmt.verb, err)
+				}
+			}
+
+			rows, err := runSelect(ctx, tx, statement{sql: stmt.text})
+			if err != nil {
+				return fmt.Errorf("statement %d (%s) failed, transaction rolled back: %w", i+1, stmt.verb, err)
+			}
+			output.Rows = rows
+			text.WriteString("\n" + formatResults(rows, title) + "\n")
+			continue
+		}
+
+		result, err := tx.ExecContext(ctx, stmt.text)
+		if err != nil {
+			return fmt.Errorf("statement %d (%s) failed, transaction rolled back: %w", i+1, stmt.verb, err)
+		}
+		affected, _ := result.RowsAffected()
+		output.Affected += affected
+		text.WriteString(fmt.Sprintf("\n✓ %s\n\nAffected %d row(s)\n", title, affected))
+	}
+	return nil
+}
+
+// transactionStep is a fully built operation of query_transaction. All steps
+// are built before the transaction starts so that catalog lookups never run
+// on a second connection while the transaction holds one.
+type transactionStep struct {
+	operation string
+	table     string
+	stmt      statement
+	inserts   []insertBatch
+	mutation  mutation
+}
+
+func QueryTransaction(ctx context.Context, req *mcp.CallToolRequest, input QueryTransactionInput) (*mcp.CallToolResult, TransactionOutput, error) {
+	if len(input.Operations) == 0 {
+		return nil, TransactionOutput{}, fmt.Errorf("at least one operation is required")
+	}
+
+	var database string
+	var pool *dbPool
+	steps := make([]transactionStep, len(input.Operations))
+
+	for i, op := range input.Operations {
+		var opDatabase string
+		set := 0
+		if op.Select != nil {
+			set, opDatabase = set+1, op.Select.Database
+		}
+		if op.Insert != nil {
+			set, opDatabase = set+1, op.Insert.Database
+		}
+		if op.Update != nil {
+			set, opDatabase = set+1, op.Update.Database
+		}
+		if op.Delete != nil {
+			set, opDatabase = set+1, op.Delete.Database
+		}
+		if set != 1 {
+			return nil, TransactionOutput{}, fmt.Errorf("operation %d must set exactly one of select, insert, update or delete", i+1)
+		}
+
+		if i == 0 {
+			database = opDatabase
+			var err error
+			if pool, err = getPool(database); err != nil {
+				return nil, TransactionOutput{}, err
+			}
+		} else if opDatabase != database {
+			return nil, TransactionOutput{}, fmt.Errorf("operation %d targets database '%s', but a transaction can only use '%s'", i+1, opDatabase, database)
+		}
+
+		if op.Select == nil && readOnly {
+			return nil, TransactionOutput{}, fmt.Errorf("database is in read-only mode")
+		}
+		if (op.Insert != nil && op.Insert.DryRun) || (op.Update != nil && op.Update.DryRun) || (op.Delete != nil && op.Delete.DryRun) {
+			return nil, TransactionOutput{}, fmt.Errorf("operation %d: dry_run is not supported inside a transaction", i+1)
+		}
+		if (op.Select != nil && op.Select.TimeoutMS != 0) || (op.Insert != nil && op.Insert.TimeoutMS != 0) || (op.Update != nil && op.Update.TimeoutMS != 0) || (op.Delete != nil && op.Delete.TimeoutMS != 0) {
+			return nil, TransactionOutput{}, fmt.Errorf("operation %d: timeout_ms applies to the whole transaction, set it next to operations", i+1)
+		}
+
+		var err error
+		switch {
+		case op.Select != nil:
+			steps[i] = transactionStep{operation: "SELECT", table: op.Select.Table}
+			steps[i].stmt, err = buildSelect(ctx, pool, *op.Select)
+		case op.Insert != nil:
+			steps[i] = transactionStep{operation: "INSERT", table: op.Insert.Table}
+			var rows []map[string]interface{}
+			if rows, err = insertRows(op.Insert.Data); err == nil {
+				steps[i].inserts, err = buildInsert(ctx, pool, *op.Insert, rows)
+			}
+		case op.Update != nil:
+			steps[i] = transactionStep{operation: "UPDATE", table: op.Update.Table}
+			steps[i].mutation, err = buildUpdate(ctx, pool, *op.Update)
+		case op.Delete != nil:
+			steps[i] = transactionStep{operation: "DELETE", table: op.Delete.Table}
+			steps[i].mutation, err = buildDelete(ctx, pool, *op.Delete)
+		}
+		if err != nil {
+			return nil, TransactionOutput{}, fmt.Errorf("operation %d (%s %s): %w", i+1, steps[i].operation, steps[i].table, err)
+		}
+	}
+
+	output := TransactionOutput{Steps: []TransactionStepOutput{}}
+	err := withConnTx(ctx, pool.db, timeoutFor(input.TimeoutMS), func(ctx context.Context, tx *sql.Tx) error {
+		for i, step := range steps {
+			var err error
+			result := TransactionStepOutput{Operation: step.operation, Table: step.table}
+			switch step.operation {
+			case "SELECT":
+				if err = checkCost(ctx, tx, database, step.stmt); err == nil {
+					result.Rows, err = runSelect(ctx, tx, step.stmt)
+				}
+			case "INSERT":
+				var inserted, updated int64
+				inserted, updated, _, result.Rows, err = runInsert(ctx, tx, database, step.inserts)
+				result.Affected = inserted + updated
+			case "UPDATE":
+				result.Affected, result.Rows, err = runLimited(ctx, tx, database, "UPDATE", step.mutation, maxUpdateLimit)
+			case "DELETE":
+				result.Affected, result.Rows, err = runLimited(ctx, tx, database, "DELETE", step.mutation, maxDeleteLimit)
+			}
+			if err != nil {
+				return fmt.Errorf("operation %d (%s %s) failed, transaction rolled back: %w", i+1, step.operation, step.table, err)
+			}
+			output.Steps = append(output.Steps, result)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, TransactionOutput{}, err
+	}
+	output.Message = "Transaction committed"
+
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("✓ Transaction committed\n\nRan %d operation(s) on %s:\n", len(output.Steps), database))
+	for i, step := range output.Steps {
+		title := fmt.Sprintf("%d. %s %s", i+1, step.Operation, step.Table)
+		if step.Operation == "SELECT" {
+			text.WriteString("\n" + formatResults(step.Rows, title) + "\n")
+		} else {
+			text.WriteString(fmt.Sprintf("\n✓ %s\n\nAffected %d row(s)\n", title, step.Affected))
+			if step.Rows != nil {
+				text.WriteString("\n" + formatResults(step.Rows, "Returned rows") + "\n")
+			}
+		}
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{
+				Text: text.String(),
+			},
+		},
+	}, output, nil
 }
 
+// previewMutation runs the mutation's COUNT(*) and sample query without
+// executing it, both after the cost guard. When data is given (an UPDATE),
+// the sample is also shown with data applied.
+func previewMutation(ctx context.Context, q queryer, database string, m mutation, data map[string]interface{}, limit int) (*DryRunOutput, error) {
+	rowCount, err := countRows(ctx, q, database, m)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkCost(ctx, q, database, m.sample); err != nil {
+		return nil, err
+	}
+	before, err := runSelect(ctx, q, m.sample)
+	if err != nil {
+		return nil, err
+	}
+	if before == nil {
+		before = []map[string]interface{}{}
+	}
+
+	preview := &DryRunOutput{
+		SQL:          m.stmt.sql,
+		Args:         m.stmt.args,
+		Rows:         rowCount,
+		Before:       before,
+		ExceedsLimit: rowCount > int64(limit),
+	}
+	if data != nil {
+		for _, row := range before {
+			after := make(map[string]interface{}, len(row))
+			for col, val := range row {
+				after[col] = val
+			}
+			for col, val := range data {
+				after[col] = val
+			}
+			preview.After = append(preview.After, after)
+		}
+	}
+	return preview, nil
+}
+
+func dryRunResult(title string, preview *DryRunOutput) (*mcp.CallToolResult, QueryOutput, error) {
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{
+				Text: formatDryRun(title, preview),
+			},
+		},
+	}, QueryOutput{Message: "Dry run, nothing was changed", DryRun: preview}, nil
+}
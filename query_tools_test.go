@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+func TestBuildUpsertClause(t *testing.T) {
+	names := []string{"email", "id", "name"}
+	tests := []struct {
+		name      string
+		dialect   Dialect
+		update    []string
+		clause    string
+		unchanged bool
+	}{
+		{"default update on postgres", postgresDialect{}, nil, `ON CONFLICT ("id") DO UPDATE SET "email" = excluded."email", "name" = excluded."name"`, false},
+		{"listed update on postgres", postgresDialect{}, []string{"name"}, `ON CONFLICT ("id") DO UPDATE SET "name" = excluded."name"`, false},
+		{"empty update on postgres", postgresDialect{}, []string{}, `ON CONFLICT ("id") DO NOTHING`, true},
+		{"default update on sqlite", sqliteDialect{}, nil, `ON CONFLICT ("id") DO UPDATE SET "email" = excluded."email", "name" = excluded."name"`, false},
+		{"empty update on sqlite", sqliteDialect{}, []string{}, `ON CONFLICT ("id") DO NOTHING`, true},
+		{"default update on mysql", mysqlDialect{}, nil, "ON DUPLICATE KEY UPDATE `email` = VALUES(`email`), `name` = VALUES(`name`)", false},
+		{"listed update on mysql", mysqlDialect{}, []string{"name"}, "ON DUPLICATE KEY UPDATE `name` = VALUES(`name`)", false},
+		{"empty update on mysql", mysqlDialect{}, []string{}, "ON DUPLICATE KEY UPDATE `id` = `id`", true},
+	}
+
+	defer func(d Dialect) { dialect = d }(dialect)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dialect = tt.dialect
+			clause, target, unchanged, err := buildUpsertClause(&OnConflict{Columns: []string{"id"}, Update: tt.update}, names)
+			if err != nil {
+				t.Fatalf("buildUpsertClause: %v", err)
+			}
+			if clause != tt.clause {
+				t.Errorf("clause = %s, want %s", clause, tt.clause)
+			}
+			if len(target) != 1 || target[0] != dialect.QuoteIdentifier("id") {
+				t.Errorf("target = %v, want the quoted id column", target)
+			}
+			if unchanged != tt.unchanged {
+				t.Errorf("unchanged = %v, want %v", unchanged, tt.unchanged)
+			}
+		})
+	}
+}
+
+func TestBuildUpsertClauseKeyOnly(t *testing.T) {
+	defer func(d Dialect) { dialect = d }(dialect)
+	dialect = postgresDialect{}
+
+	// With every inserted column in the target, the default updates nothing
+	clause, _, unchanged, err := buildUpsertClause(&OnConflict{Columns: []string{"id"}}, []string{"id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clause != `ON CONFLICT ("id") DO NOTHING` || !unchanged {
+		t.Errorf("clause = %s, unchanged = %v, want DO NOTHING", clause, unchanged)
+	}
+}
+
+func TestBuildUpsertClauseErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		onConflict OnConflict
+		err        string
+	}{
+		{"no target", OnConflict{}, "on_conflict.columns must name"},
+		{"update column not inserted", OnConflict{Columns: []string{"id"}, Update: []string{"age"}}, "'age' is not in data"},
+		{"invalid target", OnConflict{Columns: []string{"a..b"}}, "invalid on_conflict column"},
+	}
+
+	defer func(d Dialect) { dialect = d }(dialect)
+	dialect = postgresDialect{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, _, err := buildUpsertClause(&tt.onConflict, []string{"id", "name"})
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("buildUpsertClause error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestCheckUpsertTarget(t *testing.T) {
+	schema := &SchemaOutput{
+		Table: "users",
+		Columns: []ColumnInfo{
+			{Name: "id", PrimaryKey: true},
+			{Name: "email"},
+			{Name: "org"},
+			{Name: "slug"},
+			{Name: "name"},
+		},
+		Indexes: []IndexInfo{
+			{Name: "users_email", Unique: true, Columns: []string{"email"}},
+			{Name: "users_org_slug", Unique: true, Columns: []string{"org", "slug"}},
+			{Name: "users_name", Columns: []string{"name"}},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		columns []string
+		names   []string
+		err     string
+	}{
+		{"primary key", []string{"id"}, []string{"id", "name"}, ""},
+		{"unique index", []string{"email"}, []string{"email", "name"}, ""},
+		{"composite unique index in any order", []string{"slug", "org"}, []string{"org", "slug", "name"}, ""},
+		{"other key partly inserted", []string{"email"}, []string{"email", "org", "name"}, ""},
+		{"non-unique index", []string{"name"}, []string{"name"}, "must be exactly the primary key or the columns of one unique index"},
+		{"part of a composite index", []string{"org"}, []string{"org", "name"}, "must be exactly the primary key or the columns of one unique index"},
+		{"primary key also inserted", []string{"email"}, []string{"id", "email", "name"}, "also sets unique key PRIMARY (id)"},
+		{"other unique index also inserted", []string{"id"}, []string{"id", "email"}, "also sets unique key users_email (email)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkUpsertTarget(schema, tt.columns, tt.names)
+			if tt.err == "" {
+				if err != nil {
+					t.Errorf("checkUpsertTarget: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("checkUpsertTarget error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestRunLimitedRollsBack(t *testing.T) {
+	defer func(d Dialect) { dialect = d }(dialect)
+	dialect = sqliteDialect{}
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec("CREATE TABLE t (id INTEGER PRIMARY KEY); INSERT INTO t VALUES (1), (2), (3)"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		count string
+		err   string
+	}{
+		// The pre-check refuses before anything runs
+		{"count over the limit", "SELECT COUNT(*) FROM t", "DELETE would affect 3 row(s)"},
+		// Rows matched after the count are caught by the affected rows
+		{"affected over the limit", "SELECT 1", "DELETE affected 3 row(s), which exceeds the maximum limit of 2. The change was rolled back"},
+	}
+
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := mutation{stmt: statement{sql: "DELETE FROM t"}, count: statement{sql: tt.count}}
+			err := withTx(ctx, db, func(tx *sql.Tx) error {
+				_, _, err := runLimited(ctx, tx, "main", "DELETE", m, 2)
+				return err
+			})
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("runLimited error = %v, want %q", err, tt.err)
+			}
+
+			var rows int
+			if err := db.QueryRow("SELECT COUNT(*) FROM t").Scan(&rows); err != nil {
+				t.Fatal(err)
+			}
+			if rows != 3 {
+				t.Errorf("%d row(s) left after the refused DELETE, want 3", rows)
+			}
+		})
+	}
+}
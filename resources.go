@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Rows returned by the table sample resource
+const resourceSampleRows = 10
+
+// registerResources exposes every allowlisted database as a resource and
+// registers templates for table schemas and row samples:
+//
+//	sql://{database}                          tables in the default schema
+//	sql://{database}/{schema}/{table}/schema  table definition
+//	sql://{database}/{schema}/{table}/sample  first rows of the table
+//
+// The database is the URI host, so only databases whose names are plain URI
+// characters (see isURIHostSafe) are exposed; schema and table segments may be
+// percent-encoded. The schema segment is ignored on MySQL.
+func registerResources(server *mcp.Server) {
+	for _, database := range dbNames {
+		if !isURIHostSafe(database) {
+			log.Printf("Warning: database %q cannot be used as a resource URI host, skipping its resources", database)
+			continue
+		}
+		server.AddResource(&mcp.Resource{
+			URI:         "sql://" + database,
+			Name:        database,
+			Description: fmt.Sprintf("Tables in the %s database", database),
+			MIMEType:    "text/plain",
+		}, ReadDatabaseResource)
+	}
+
+	server.AddResourceTemplate(&mcp.ResourceTemplate{
+		URITemplate: "sql://{database}/{schema}/{table}/schema",
+		Name:        "table-schema",
+		Description: "Columns, types, keys, foreign keys and indexes of a table",
+		MIMEType:    "text/plain",
+	}, ReadTableSchemaResource)
+
+	server.AddResourceTemplate(&mcp.ResourceTemplate{
+		URITemplate: "sql://{database}/{schema}/{table}/sample",
+		Name:        "table-sample",
+		Description: fmt.Sprintf("The first %d rows of a table", resourceSampleRows),
+		MIMEType:    "text/markdown",
+	}, ReadTableSampleResource)
+}
+
+func ReadDatabaseResource(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+	parts, err := parseResourceURI(req.Params.URI, 1)
+	if err != nil {
+		return nil, err
+	}
+	database := parts[0]
+
+	pool, err := getPool(database)
+	if err != nil {
+		return nil, mcp.ResourceNotFoundError(req.Params.URI)
+	}
+
+	tables, err := dialect.ListTables(ctx, pool.db, database, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tables: %w", err)
+	}
+
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("Tables in %s:\n\n", database))
+	for _, table := range tables {
+		output.WriteString(fmt.Sprintf("• %s\n", table))
+	}
+
+	return &mcp.ReadResourceResult{
+		Contents: []*mcp.ResourceContents{{Text: output.String()}},
+	}, nil
+}
+
+func ReadTableSchemaResource(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+	parts, err := parseResourceURI(req.Params.URI, 4)
+	if err != nil {
+		return nil, err
+	}
+	database, schemaName, table := parts[0], parts[1], parts[2]
+
+	pool, err := getPool(database)
+	if err != nil {
+		return nil, mcp.ResourceNotFoundError(req.Params.URI)
+	}
+
+	schema, err := dialect.TableSchema(ctx, pool.db, database, schemaName, table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get table schema: %w", err)
+	}
+	if len(schema.Columns) == 0 {
+		return nil, mcp.ResourceNotFoundError(req.Params.URI)
+	}
+
+	return &mcp.ReadResourceResult{
+		Contents: []*mcp.ResourceContents{{Text: formatTableSchema(schema)}},
+	}, nil
+}
+
+func ReadTableSampleResource(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+	parts, err := parseResourceURI(req.Params.URI, 4)
+	if err != nil {
+		return nil, err
+	}
+	database, schemaName, table := parts[0], parts[1], parts[2]
+
+	pool, err := getPool(database)
+	if err != nil {
+		return nil, mcp.ResourceNotFoundError(req.Params.URI)
+	}
+
+	tableName, err := dialect.QualifiedTable(ctx, pool.db, database, schemaName, table)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := resourceSampleRows
+	if limit > maxSelectLimit {
+		limit = maxSelectLimit
+	}
+
+	sqlQuery, args, err := pool.qb.Select("*").From(tableName).Limit(uint64(limit)).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	// Run like query_select: read-only session, query timeout and cost guard
+	stmt := statement{sql: sqlQuery, args: args}
+	var results []map[string]interface{}
+	err = withSession(ctx, pool.db, timeoutFor(0), func(ctx context.Context, q queryer) error {
+		if err := checkCost(ctx, q, database, stmt); err != nil {
+			return err
+		}
+		results, err = runSelect(ctx, q, stmt)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &mcp.ReadResourceResult{
+		Contents: []*mcp.ResourceContents{{Text: formatResults(results, fmt.Sprintf("Sample of %s.%s", database, table))}},
+	}, nil
+}
+
+// isURIHostSafe reports whether name consists only of RFC 3986 unreserved
+// characters and can therefore appear unescaped as the host of a sql:// URI.
+func isURIHostSafe(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("-._~", c) >= 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseResourceURI splits a sql:// URI into its unescaped path segments and
+// checks that there are exactly n of them.
+func parseResourceURI(uri string, n int) ([]string, error) {
+	rest, ok := strings.CutPrefix(uri, "sql://")
+	if !ok {
+		return nil, mcp.ResourceNotFoundError(uri)
+	}
+
+	parts := strings.Split(rest, "/")
+	if len(parts) != n {
+		return nil, mcp.ResourceNotFoundError(uri)
+	}
+	if !isURIHostSafe(parts[0]) {
+		return nil, mcp.ResourceNotFoundError(uri)
+	}
+	for i, part := range parts {
+		unescaped, err := url.PathUnescape(part)
+		if err != nil || unescaped == "" {
+			return nil, mcp.ResourceNotFoundError(uri)
+		}
+		parts[i] = unescaped
+	}
+	return parts, nil
+}
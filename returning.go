@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// returning describes how an INSERT, UPDATE or DELETE reports the rows it
+// changed. Engines with a RETURNING clause return them from the statement
+// itself; otherwise (MySQL) the rows are read back by key with a SELECT in the
+// same transaction.
+type returning struct {
+	columns []string // quoted columns to return
+	native  bool
+
+	// Read-back only
+	qb         sq.StatementBuilderType
+	database   string
+	table      string   // quoted table name
+	keys       []string // key columns identifying a row
+	quotedKeys []string
+}
+
+// buildReturning validates the columns requested by a mutation on table and
+// returns nil when none were requested. keys identifies rows for read-back
+// and defaults to the table's primary key.
+func buildReturning(ctx context.Context, pool *dbPool, database, schema, table, tableName string, columns, keys []string) (*returning, error) {
+	if len(columns) == 0 {
+		return nil, nil
+	}
+
+	r := &returning{native: dialect.SupportsReturning(), qb: pool.qb, database: database, table: tableName}
+	for _, col := range columns {
+		if strings.TrimSpace(col) == "*" {
+			r.columns = append(r.columns, "*")
+			continue
+		}
+		quoted, err := quoteIdentifier(col)
+		if err != nil {
+			return nil, fmt.Errorf("invalid returning column: %w", err)
+		}
+		r.columns = append(r.columns, quoted)
+	}
+	if r.native {
+		return r, nil
+	}
+
+	if len(keys) == 0 {
+		schemaInfo, err := dialect.TableSchema(ctx, pool.db, database, schema, table)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get table schema: %w", err)
+		}
+		for _, col := range schemaInfo.Columns {
+			if col.PrimaryKey {
+				keys = append(keys, col.Name)
+			}
+		}
+		if len(keys) == 0 {
+			return nil, fmt.Errorf("returning requires table '%s' to have a primary key on %s", table, dialect.Name())
+		}
+	}
+
+	r.keys = keys
+	for _, key := range keys {
+		r.quotedKeys = append(r.quotedKeys, dialect.QuoteIdentifier(key))
+	}
+	return r, nil
+}
+
+// clause returns the RETURNING clause appended to a statement.
+func (r *returning) clause() string {
+	return "RETURNING " + strings.Join(r.columns, ", ")
+}
+
+// selectByKeys reads back the rows whose keys equal those of keyRows.
+func (r *returning) selectByKeys(ctx context.Context, q queryer, keyRows []map[string]interface{}) ([]map[string]interface{}, error) {
+	if len(keyRows) == 0 {
+		return []map[string]interface{}{}, nil
+	}
+
+	match := sq.Or{}
+	for _, row := range keyRows {
+		key := sq.Eq{}
+		for i, col := range r.keys {
+			if row[col] == nil {
+				return nil, fmt.Errorf("cannot read back changed rows: key column '%s' has no value", col)
+			}
+			key[r.quotedKeys[i]] = row[col]
+		}
+		match = append(match, key)
+	}
+	return r.selectWhere(ctx, q, match)
+}
+
+// hasKeys reports whether every row sets all key columns.
+func (r *returning) hasKeys(rows []map[string]interface{}) bool {
+	for _, row := range rows {
+		for _, col := range r.keys {
+			if row[col] == nil {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// selectInserted reads back the rows of an INSERT batch. Rows are found by
+// their keys when the batch sets them, otherwise from lastID for a single
+// auto-increment key. The ids of a multi-row INSERT need not be consecutive
+// (auto_increment_increment, interleaved lock mode, Galera), so such a batch
+// must hold a single row.
+func (r *returning) selectInserted(ctx context.Context, q queryer, batch []map[string]interface{}, lastID int64) ([]map[string]interface{}, error) {
+	if r.hasKeys(batch) {
+		return r.selectByKeys(ctx, q, batch)
+	}
+
+	if len(r.keys) != 1 || len(batch) != 1 || lastID == 0 {
+		return nil, fmt.Errorf("cannot read back inserted rows: include the key column(s) %s in data", strings.Join(r.keys, ", "))
+	}
+	return r.selectWhere(ctx, q, sq.Eq{r.quotedKeys[0]: lastID})
+}
+
+func (r *returning) selectWhere(ctx context.Context, q queryer, where sq.Sqlizer) ([]map[string]interface{}, error) {
+	sqlQuery, args, err := r.qb.Select(r.columns...).From(r.table).Where(where).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build returning query: %w", err)
+	}
+
+	stmt := statement{sql: sqlQuery, args: args}
+	if err := checkCost(ctx, q, r.database, stmt); err != nil {
+		return nil, err
+	}
+	rows, err := runSelect(ctx, q, stmt)
+	if err != nil {
+		return nil, err
+	}
+	if rows == nil {
+		rows = []map[string]interface{}{}
+	}
+	return rows, nil
+}
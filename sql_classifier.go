@@ -0,0 +1,288 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// sqlStatement is one statement of a raw query as seen by the classifier.
+type sqlStatement struct {
+	text        string // statement text without the terminating semicolon
+	verb        string // the statement's main keyword, e.g. SELECT or DELETE
+	readOnly    bool   // the statement only reads data
+	returnsRows bool   // the statement produces a result set
+}
+
+// readVerbs are the statements that only read data, as long as none of
+// writeKeywords appears anywhere in them.
+var readVerbs = map[string]bool{
+	"SELECT": true, "VALUES": true, "TABLE": true,
+	"SHOW": true, "DESCRIBE": true, "DESC": true, "EXPLAIN": true,
+}
+
+// writeKeywords mark a statement as a write wherever they appear, which
+// catches data-modifying CTEs, EXPLAIN ANALYZE of a write and SELECT INTO.
+// Keywords that are also function names (REPLACE, TRUNCATE) are only
+// recognised as the statement's verb.
+var writeKeywords = map[string]bool{
+	"INSERT": true, "UPDATE": true, "DELETE": true, "MERGE": true,
+	"CREATE": true, "DROP": true, "ALTER": true, "GRANT": true, "REVOKE": true,
+	"INTO": true,
+}
+
+// rowVerbs are the statements that produce a result set; any other statement
+// does so only with a RETURNING clause.
+var rowVerbs = map[string]bool{
+	"SELECT": true, "VALUES": true, "TABLE": true,
+	"SHOW": true, "DESCRIBE": true, "DESC": true, "EXPLAIN": true, "PRAGMA": true,
+}
+
+// cteVerbs are the statements a WITH clause can precede.
+var cteVerbs = map[string]bool{
+	"SELECT": true, "VALUES": true, "TABLE": true,
+	"INSERT": true, "UPDATE": true, "DELETE": true, "MERGE": true, "REPLACE": true,
+}
+
+// sqlWord is a bare word of a statement together with its parenthesis depth.
+type sqlWord struct {
+	text  string
+	depth int
+}
+
+// classifySQL splits a raw query into statements and classifies each one.
+// Comments, string literals, quoted identifiers and PostgreSQL dollar-quoted
+// bodies are skipped, so keywords and semicolons inside them are ignored.
+// Statements that contain nothing but comments are dropped.
+func classifySQL(query string) ([]sqlStatement, error) {
+	mysql := dialect.Name() == "mysql"
+	postgres := dialect.Name() == "postgres"
+
+	var statements []sqlStatement
+	var words []sqlWord
+	depth, start := 0, 0
+	inHint := false // inside a MySQL /*! ... */ comment, whose body is executed
+
+	finish := func(end int) {
+		if len(words) > 0 {
+			statements = append(statements, classifyStatement(strings.TrimSpace(query[start:end]), words))
+		}
+		words, depth, start = nil, 0, end+1
+	}
+
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ';':
+			finish(i)
+			i++
+
+		case c == '-' && strings.HasPrefix(query[i:], "--") && (!mysql || i+2 == len(query) || query[i+2] <= ' '), c == '#' && mysql:
+			// On MySQL, -- starts a comment only when followed by whitespace,
+			// a control character or the end of the query: "1 --1" is 1 - -1
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				i = len(query)
+			} else {
+				i += end + 1
+			}
+
+		case c == '*' && inHint && strings.HasPrefix(query[i:], "*/"):
+			inHint = false
+			i += 2
+
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			if mysql && strings.HasPrefix(query[i:], "/*!") {
+				// Executable comment: skip the marker and optional version
+				// and classify its body like any other SQL
+				i += 3
+				for i < len(query) && query[i] >= '0' && query[i] <= '9' {
+					i++
+				}
+				inHint = true
+				continue
+			}
+			end, err := skipBlockComment(query, i, postgres)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+
+		case c == '\'':
+			// Backslash escapes apply to every MySQL string and to
+			// PostgreSQL E'...' strings
+			escapes := mysql || (postgres && i > 0 && (query[i-1] == 'E' || query[i-1] == 'e') && (i == 1 || !isWordPart(query[i-2])))
+			end, err := skipQuoted(query, i, '\'', escapes)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+
+		case c == '"':
+			end, err := skipQuoted(query, i, '"', mysql)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+
+		case c == '`':
+			end, err := skipQuoted(query, i, '`', false)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+
+		case c == '[' && dialect.Name() == "sqlite":
+			end := strings.IndexByte(query[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quoted identifier in query")
+			}
+			i += end + 1
+
+		case c == '$' && postgres:
+			end, ok, err := skipDollarQuoted(query, i)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				// A $1 placeholder
+				i++
+				for i < len(query) && query[i] >= '0' && query[i] <= '9' {
+					i++
+				}
+			} else {
+				i = end
+			}
+
+		case c == '(':
+			depth++
+			i++
+
+		case c == ')':
+			depth--
+			i++
+
+		case isWordStart(c):
+			end := i + 1
+			for end < len(query) && isWordPart(query[end]) {
+				end++
+			}
+			words = append(words, sqlWord{text: strings.ToUpper(query[i:end]), depth: depth})
+			i = end
+
+		default:
+			i++
+		}
+	}
+	finish(len(query))
+
+	return statements, nil
+}
+
+// classifyStatement determines the verb of a statement from its words and
+// whether it only reads data and produces rows.
+func classifyStatement(text string, words []sqlWord) sqlStatement {
+	stmt := sqlStatement{text: text, verb: words[0].text}
+	if stmt.verb == "WITH" {
+		// The main statement is the first verb after the CTE definitions,
+		// which are all enclosed in parentheses
+		for _, word := range words[1:] {
+			if word.depth == 0 && cteVerbs[word.text] {
+				stmt.verb = word.text
+				break
+			}
+		}
+	}
+
+	returning := false
+	stmt.readOnly = readVerbs[stmt.verb]
+	for _, word := range words {
+		if writeKeywords[word.text] {
+			stmt.readOnly = false
+		}
+		if word.text == "RETURNING" {
+			returning = true
+		}
+	}
+	stmt.returnsRows = rowVerbs[stmt.verb] || returning
+	return stmt
+}
+
+// skipBlockComment returns the position after the /* comment starting at i.
+// PostgreSQL block comments nest.
+func skipBlockComment(query string, i int, nested bool) (int, error) {
+	level := 0
+	for i < len(query) {
+		switch {
+		case strings.HasPrefix(query[i:], "/*"):
+			if level > 0 && !nested {
+				i += 2
+				continue
+			}
+			level++
+			i += 2
+		case strings.HasPrefix(query[i:], "*/"):
+			level--
+			i += 2
+			if level == 0 {
+				return i, nil
+			}
+		default:
+			i++
+		}
+	}
+	return 0, fmt.Errorf("unterminated comment in query")
+}
+
+// skipQuoted returns the position after the literal or quoted identifier
+// starting at i. A doubled quote is an escaped quote, as is a backslash
+// followed by any character when escapes is set.
+func skipQuoted(query string, i int, quote byte, escapes bool) (int, error) {
+	for i++; i < len(query); i++ {
+		switch query[i] {
+		case '\\':
+			if escapes {
+				i++
+			}
+		case quote:
+			if i+1 < len(query) && query[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1, nil
+		}
+	}
+	if quote == '\'' {
+		return 0, fmt.Errorf("unterminated string literal in query")
+	}
+	return 0, fmt.Errorf("unterminated quoted identifier in query")
+}
+
+// skipDollarQuoted returns the position after the PostgreSQL dollar-quoted
+// string ($$...$$ or $tag$...$tag$) starting at i, or ok false when the $ does
+// not open one.
+func skipDollarQuoted(query string, i int) (end int, ok bool, err error) {
+	j := i + 1
+	if j < len(query) && isWordStart(query[j]) {
+		for j < len(query) && isWordPart(query[j]) && query[j] != '$' {
+			j++
+		}
+	}
+	if j >= len(query) || query[j] != '$' {
+		return 0, false, nil
+	}
+
+	tag := query[i : j+1]
+	closing := strings.Index(query[j+1:], tag)
+	if closing < 0 {
+		return 0, false, fmt.Errorf("unterminated dollar-quoted string in query")
+	}
+	return j + 1 + closing + len(tag), true, nil
+}
+
+func isWordStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isWordPart(c byte) bool {
+	return isWordStart(c) || (c >= '0' && c <= '9') || c == '$'
+}
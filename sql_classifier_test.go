@@ -0,0 +1,140 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClassifySQL(t *testing.T) {
+	type want struct {
+		verb        string
+		readOnly    bool
+		returnsRows bool
+	}
+	read := func(verb string) want { return want{verb: verb, readOnly: true, returnsRows: true} }
+	write := func(verb string) want { return want{verb: verb} }
+
+	tests := []struct {
+		name    string
+		dialect Dialect
+		query   string
+		want    []want
+	}{
+		{"select", postgresDialect{}, "SELECT * FROM users", []want{read("SELECT")}},
+		{"trailing semicolon", postgresDialect{}, "SELECT 1;", []want{read("SELECT")}},
+		{"lowercase", sqliteDialect{}, "select 1", []want{read("SELECT")}},
+		{"values", postgresDialect{}, "VALUES (1), (2)", []want{read("VALUES")}},
+		{"table", postgresDialect{}, "TABLE users", []want{read("TABLE")}},
+		{"show", mysqlDialect{}, "SHOW TABLES", []want{read("SHOW")}},
+		{"pragma returns rows but writes", sqliteDialect{}, "PRAGMA table_info(users)", []want{{verb: "PRAGMA", returnsRows: true}}},
+		{"delete", postgresDialect{}, "DELETE FROM users", []want{write("DELETE")}},
+		{"update returning", postgresDialect{}, "UPDATE users SET a = 1 RETURNING id", []want{{verb: "UPDATE", returnsRows: true}}},
+		{"select into", postgresDialect{}, "SELECT * INTO copy FROM users", []want{{verb: "SELECT", returnsRows: true}}},
+		{"replace as verb", mysqlDialect{}, "REPLACE INTO users VALUES (1)", []want{write("REPLACE")}},
+		{"replace as function", mysqlDialect{}, "SELECT REPLACE(name, 'a', 'b') FROM users", []want{read("SELECT")}},
+
+		// CTEs
+		{"cte select", postgresDialect{}, "WITH x AS (SELECT 1) SELECT * FROM x", []want{read("SELECT")}},
+		{"cte delete", postgresDialect{}, "WITH x AS (SELECT id FROM a) DELETE FROM b WHERE id IN (SELECT id FROM x)", []want{write("DELETE")}},
+		{"write hidden in cte", postgresDialect{}, "WITH gone AS (DELETE FROM users RETURNING *) SELECT * FROM gone", []want{{verb: "SELECT", returnsRows: true}}},
+		{"recursive cte", sqliteDialect{}, "WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM c) SELECT x FROM c", []want{read("SELECT")}},
+		{"explain analyze of a write", postgresDialect{}, "EXPLAIN ANALYZE DELETE FROM users", []want{{verb: "EXPLAIN", returnsRows: true}}},
+
+		// Comments
+		{"line comment", postgresDialect{}, "-- DELETE FROM users\nSELECT 1", []want{read("SELECT")}},
+		{"block comment", postgresDialect{}, "/* DROP TABLE users; */ SELECT 1", []want{read("SELECT")}},
+		{"nested comment on postgres", postgresDialect{}, "/* a /* DROP TABLE users; */ still comment */ SELECT 1", []want{read("SELECT")}},
+		{"comments do not nest on mysql", mysqlDialect{}, "/* a /* b */ SELECT 1", []want{read("SELECT")}},
+		{"hash comment on mysql", mysqlDialect{}, "# DELETE FROM users\nSELECT 1", []want{read("SELECT")}},
+		{"double dash without space on mysql", mysqlDialect{}, "SELECT 1 --1; DELETE FROM users", []want{read("SELECT"), write("DELETE")}},
+		{"double dash with tab on mysql", mysqlDialect{}, "SELECT 1 --\tDELETE FROM users\n", []want{read("SELECT")}},
+		{"double dash at end on mysql", mysqlDialect{}, "SELECT 1 --", []want{read("SELECT")}},
+		{"double dash without space on postgres", postgresDialect{}, "SELECT 1 --1; DELETE FROM users", []want{read("SELECT")}},
+		{"comment only", postgresDialect{}, "SELECT 1; -- done", []want{read("SELECT")}},
+		{"mysql executable comment", mysqlDialect{}, "SELECT 1 /*!50000 INTO OUTFILE '/tmp/x' */", []want{{verb: "SELECT", returnsRows: true}}},
+		{"mysql executable comment verb", mysqlDialect{}, "/*! DELETE FROM users */", []want{write("DELETE")}},
+		{"executable comment is plain on postgres", postgresDialect{}, "SELECT 1 /*! DELETE FROM users */", []want{read("SELECT")}},
+
+		// Strings and identifiers
+		{"keyword in string", postgresDialect{}, "SELECT 'DELETE FROM users; DROP' AS s", []want{read("SELECT")}},
+		{"doubled quote", postgresDialect{}, "SELECT 'it''s; DELETE' AS s", []want{read("SELECT")}},
+		{"backslash is literal on postgres", postgresDialect{}, `SELECT 'a\'; DELETE FROM users`, []want{read("SELECT"), write("DELETE")}},
+		{"escape string on postgres", postgresDialect{}, `SELECT E'a\'; DELETE FROM users'`, []want{read("SELECT")}},
+		{"backslash escape on mysql", mysqlDialect{}, `SELECT 'a\'; DELETE FROM users'`, []want{read("SELECT")}},
+		{"quoted identifier", postgresDialect{}, `SELECT "delete;" FROM users`, []want{read("SELECT")}},
+		{"backtick identifier", mysqlDialect{}, "SELECT `update;` FROM users", []want{read("SELECT")}},
+		{"bracket identifier on sqlite", sqliteDialect{}, "SELECT [insert;] FROM users", []want{read("SELECT")}},
+		{"dollar quote", postgresDialect{}, "SELECT $$ DELETE FROM users; $$", []want{read("SELECT")}},
+		{"tagged dollar quote", postgresDialect{}, "SELECT $fn$ a $$ b; DROP TABLE users $fn$", []want{read("SELECT")}},
+		{"dollar placeholder", postgresDialect{}, "SELECT * FROM users WHERE id = $1; DELETE FROM users", []want{read("SELECT"), write("DELETE")}},
+
+		// Multiple statements
+		{"stacked", postgresDialect{}, "SELECT 1; DROP TABLE users", []want{read("SELECT"), write("DROP")}},
+		{"stacked with empties", sqliteDialect{}, ";; SELECT 1 ;; INSERT INTO t VALUES (1);", []want{read("SELECT"), write("INSERT")}},
+		{"empty", postgresDialect{}, "  -- nothing\n", nil},
+	}
+
+	defer func(d Dialect) { dialect = d }(dialect)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dialect = tt.dialect
+			statements, err := classifySQL(tt.query)
+			if err != nil {
+				t.Fatalf("classifySQL(%q): %v", tt.query, err)
+			}
+			if len(statements) != len(tt.want) {
+				t.Fatalf("classifySQL(%q) = %d statements, want %d: %+v", tt.query, len(statements), len(tt.want), statements)
+			}
+			for i, stmt := range statements {
+				got := want{verb: stmt.verb, readOnly: stmt.readOnly, returnsRows: stmt.returnsRows}
+				if got != tt.want[i] {
+					t.Errorf("statement %d of %q = %+v, want %+v", i+1, tt.query, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestClassifySQLText(t *testing.T) {
+	defer func(d Dialect) { dialect = d }(dialect)
+	dialect = postgresDialect{}
+
+	statements, err := classifySQL("SELECT ';' AS a ;\n  DELETE FROM t WHERE x = 1 ;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"SELECT ';' AS a", "DELETE FROM t WHERE x = 1"}
+	if len(statements) != len(want) {
+		t.Fatalf("got %d statements, want %d", len(statements), len(want))
+	}
+	for i, stmt := range statements {
+		if stmt.text != want[i] {
+			t.Errorf("statement %d text = %q, want %q", i+1, stmt.text, want[i])
+		}
+	}
+}
+
+func TestClassifySQLUnterminated(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		query   string
+		err     string
+	}{
+		{postgresDialect{}, "SELECT 'abc", "unterminated string literal"},
+		{postgresDialect{}, `SELECT "abc`, "unterminated quoted identifier"},
+		{mysqlDialect{}, "SELECT `abc", "unterminated quoted identifier"},
+		{sqliteDialect{}, "SELECT [abc", "unterminated quoted identifier"},
+		{postgresDialect{}, "SELECT 1 /* abc", "unterminated comment"},
+		{postgresDialect{}, "SELECT /* a /* b */ 1", "unterminated comment"},
+		{postgresDialect{}, "SELECT $tag$ abc", "unterminated dollar-quoted string"},
+	}
+
+	defer func(d Dialect) { dialect = d }(dialect)
+	for _, tt := range tests {
+		dialect = tt.dialect
+		_, err := classifySQL(tt.query)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("classifySQL(%q) error = %v, want %q", tt.query, err, tt.err)
+		}
+	}
+}
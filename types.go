@@ -3,48 +3,109 @@ package main
 // ===== INPUT TYPES =====
 
 type QuerySelectInput struct {
-	Database string        `json:"database" jsonschema_description:"Database name"`
-	Table    string        `json:"table" jsonschema_description:"Table name"`
-	Schema   string        `json:"schema,omitempty" jsonschema_description:"Schema name (PostgreSQL)"`
-	Columns  []string      `json:"columns,omitempty" jsonschema_description:"Columns to select (empty for all)"`
-	Where    []WhereClause `json:"where,omitempty" jsonschema_description:"WHERE conditions"`
-	OrderBy  []string      `json:"order_by,omitempty" jsonschema_description:"ORDER BY columns"`
-	Limit    int           `json:"limit,omitempty" jsonschema_description:"LIMIT rows"`
-	Offset   int           `json:"offset,omitempty" jsonschema_description:"OFFSET rows"`
+	Database   string        `json:"database" jsonschema_description:"Database name"`
+	Table      string        `json:"table" jsonschema_description:"Table name"`
+	Schema     string        `json:"schema,omitempty" jsonschema_description:"Schema name (PostgreSQL)"`
+	Alias      string        `json:"alias,omitempty" jsonschema_description:"Alias of the table, used as alias.column in joined queries"`
+	Joins      []JoinClause  `json:"joins,omitempty" jsonschema_description:"Tables to join; columns are then referenced as alias.column"`
+	Columns    []string      `json:"columns,omitempty" jsonschema_description:"Columns to select (empty for all)"`
+	Where      []WhereClause `json:"where,omitempty" jsonschema_description:"WHERE conditions"`
+	OrderBy    []string      `json:"order_by,omitempty" jsonschema_description:"ORDER BY columns or aggregate aliases"`
+	Aggregates []Aggregate   `json:"aggregates,omitempty" jsonschema_description:"Aggregates to select after columns"`
+	GroupBy    []string      `json:"group_by,omitempty" jsonschema_description:"GROUP BY columns"`
+	Having     []WhereClause `json:"having,omitempty" jsonschema_description:"HAVING conditions on aggregate aliases or grouped columns"`
+	Distinct   bool          `json:"distinct,omitempty" jsonschema_description:"Return only distinct rows"`
+	Limit      int           `json:"limit,omitempty" jsonschema_description:"LIMIT rows"`
+	Offset     int           `json:"offset,omitempty" jsonschema_description:"OFFSET rows"`
+	TimeoutMS  int           `json:"timeout_ms,omitempty" jsonschema_description:"Query timeout in milliseconds (default QUERY_TIMEOUT_MS, capped at MAX_QUERY_TIMEOUT_MS)"`
+}
+
+type Aggregate struct {
+	Func   string `json:"func" jsonschema_description:"Aggregate function: count, count_distinct, sum, avg, min or max"`
+	Column string `json:"column,omitempty" jsonschema_description:"Column to aggregate (omit for count of rows)"`
+	Alias  string `json:"alias,omitempty" jsonschema_description:"Name of the result column (default: func_column, e.g. sum_total)"`
+}
+
+type JoinClause struct {
+	Type   string   `json:"type,omitempty" jsonschema_description:"Join type: INNER, LEFT, RIGHT or FULL (default INNER)"`
+	Table  string   `json:"table" jsonschema_description:"Table to join"`
+	Schema string   `json:"schema,omitempty" jsonschema_description:"Schema of the joined table (default: the schema of the selected table)"`
+	Alias  string   `json:"alias,omitempty" jsonschema_description:"Alias of the joined table (default: the table name)"`
+	On     []JoinOn `json:"on" jsonschema_description:"Column pairs that must be equal, combined with AND"`
+}
+
+type JoinOn struct {
+	Left  string `json:"left" jsonschema_description:"Column of a table joined earlier, as alias.column"`
+	Right string `json:"right" jsonschema_description:"Column of the joined table, as alias.column"`
 }
 
+// WhereClause is a condition: either a comparison on a column, or an and, or
+// or not group of nested conditions.
 type WhereClause struct {
-	Column string      `json:"column" jsonschema_description:"Column name"`
-	Op     string      `json:"op" jsonschema_description:"Operator: =, !=, <, >, <=, >=, LIKE, IN, BETWEEN, IS NULL, IS NOT NULL"`
-	Value  interface{} `json:"value,omitempty" jsonschema_description:"Value to compare"`
+	Column string        `json:"column,omitempty" jsonschema_description:"Column name"`
+	Op     string        `json:"op,omitempty" jsonschema_description:"Operator: =, !=, <, >, <=, >=, LIKE, NOT LIKE, ILIKE, NOT ILIKE, IN, NOT IN, BETWEEN, NOT BETWEEN, IS NULL, IS NOT NULL"`
+	Value  interface{}   `json:"value,omitempty" jsonschema_description:"Value to compare"`
+	And    []WhereClause `json:"and,omitempty" jsonschema_description:"Group matching when all of these conditions match"`
+	Or     []WhereClause `json:"or,omitempty" jsonschema_description:"Group matching when any of these conditions match"`
+	Not    []WhereClause `json:"not,omitempty" jsonschema_description:"Group matching when these conditions do not all match"`
 }
 
 type QueryInsertInput struct {
-	Database string                 `json:"database" jsonschema_description:"Database name"`
-	Table    string                 `json:"table" jsonschema_description:"Table name"`
-	Schema   string                 `json:"schema,omitempty" jsonschema_description:"Schema name (PostgreSQL)"`
-	Data     map[string]interface{} `json:"data" jsonschema_description:"Column:value pairs to insert"`
+	Database   string      `json:"database" jsonschema_description:"Database name"`
+	Table      string      `json:"table" jsonschema_description:"Table name"`
+	Schema     string      `json:"schema,omitempty" jsonschema_description:"Schema name (PostgreSQL)"`
+	Data       interface{} `json:"data" jsonschema_description:"Column:value pairs to insert, or an array of them to insert several rows"`
+	DryRun     bool        `json:"dry_run,omitempty" jsonschema_description:"Preview the statement without executing it"`
+	OnConflict *OnConflict `json:"on_conflict,omitempty" jsonschema_description:"Update rows that already exist instead of failing (upsert)"`
+	Returning  []string    `json:"returning,omitempty" jsonschema_description:"Columns to return from the inserted rows (* for all)"`
+	TimeoutMS  int         `json:"timeout_ms,omitempty" jsonschema_description:"Query timeout in milliseconds (default QUERY_TIMEOUT_MS, capped at MAX_QUERY_TIMEOUT_MS)"`
+}
+
+type OnConflict struct {
+	Columns []string `json:"columns" jsonschema_description:"Columns of the primary key or unique constraint that identify an existing row"`
+	Update  []string `json:"update,omitempty" jsonschema_description:"Columns to overwrite on existing rows (default: every inserted column not in columns); [] leaves existing rows unchanged"`
 }
 
 type QueryUpdateInput struct {
-	Database string                 `json:"database" jsonschema_description:"Database name"`
-	Table    string                 `json:"table" jsonschema_description:"Table name"`
-	Schema   string                 `json:"schema,omitempty" jsonschema_description:"Schema name (PostgreSQL)"`
-	Data     map[string]interface{} `json:"data" jsonschema_description:"Column:value pairs to update"`
-	Where    []WhereClause          `json:"where" jsonschema_description:"WHERE conditions (required)"`
+	Database  string                 `json:"database" jsonschema_description:"Database name"`
+	Table     string                 `json:"table" jsonschema_description:"Table name"`
+	Schema    string                 `json:"schema,omitempty" jsonschema_description:"Schema name (PostgreSQL)"`
+	Data      map[string]interface{} `json:"data" jsonschema_description:"Column:value pairs to update"`
+	Where     []WhereClause          `json:"where" jsonschema_description:"WHERE conditions (required)"`
+	DryRun    bool                   `json:"dry_run,omitempty" jsonschema_description:"Preview the statement and affected rows without executing it"`
+	Returning []string               `json:"returning,omitempty" jsonschema_description:"Columns to return from the updated rows (* for all)"`
+	TimeoutMS int                    `json:"timeout_ms,omitempty" jsonschema_description:"Query timeout in milliseconds (default QUERY_TIMEOUT_MS, capped at MAX_QUERY_TIMEOUT_MS)"`
 }
 
 type QueryDeleteInput struct {
-	Database string        `json:"database" jsonschema_description:"Database name"`
-	Table    string        `json:"table" jsonschema_description:"Table name"`
-	Schema   string        `json:"schema,omitempty" jsonschema_description:"Schema name (PostgreSQL)"`
-	Where    []WhereClause `json:"where" jsonschema_description:"WHERE conditions (required)"`
+	Database  string        `json:"database" jsonschema_description:"Database name"`
+	Table     string        `json:"table" jsonschema_description:"Table name"`
+	Schema    string        `json:"schema,omitempty" jsonschema_description:"Schema name (PostgreSQL)"`
+	Where     []WhereClause `json:"where" jsonschema_description:"WHERE conditions (required)"`
+	DryRun    bool          `json:"dry_run,omitempty" jsonschema_description:"Preview the statement and affected rows without executing it"`
+	Returning []string      `json:"returning,omitempty" jsonschema_description:"Columns to return from the deleted rows (* for all)"`
+	TimeoutMS int           `json:"timeout_ms,omitempty" jsonschema_description:"Query timeout in milliseconds (default QUERY_TIMEOUT_MS, capped at MAX_QUERY_TIMEOUT_MS)"`
 }
 
 type QueryRawInput struct {
-	Database string        `json:"database" jsonschema_description:"Database name"`
-	Query    string        `json:"query" jsonschema_description:"Raw SQL query"`
-	Params   []interface{} `json:"params,omitempty" jsonschema_description:"Query parameters"`
+	Database  string        `json:"database" jsonschema_description:"Database name"`
+	Query     string        `json:"query" jsonschema_description:"Raw SQL query"`
+	Params    []interface{} `json:"params,omitempty" jsonschema_description:"Query parameters"`
+	DryRun    bool          `json:"dry_run,omitempty" jsonschema_description:"Return the query without executing it; affected rows are not counted or sampled"`
+	TimeoutMS int           `json:"timeout_ms,omitempty" jsonschema_description:"Query timeout in milliseconds (default QUERY_TIMEOUT_MS, capped at MAX_QUERY_TIMEOUT_MS)"`
+}
+
+type QueryTransactionInput struct {
+	Operations []TransactionOperation `json:"operations" jsonschema_description:"Operations to run in order, all against the same database"`
+	TimeoutMS  int                    `json:"timeout_ms,omitempty" jsonschema_description:"Timeout of the whole transaction in milliseconds (default QUERY_TIMEOUT_MS, capped at MAX_QUERY_TIMEOUT_MS)"`
+}
+
+// TransactionOperation is one step of a transaction. Exactly one field is set.
+type TransactionOperation struct {
+	Select *QuerySelectInput `json:"select,omitempty" jsonschema_description:"SELECT step"`
+	Insert *QueryInsertInput `json:"insert,omitempty" jsonschema_description:"INSERT step"`
+	Update *QueryUpdateInput `json:"update,omitempty" jsonschema_description:"UPDATE step"`
+	Delete *QueryDeleteInput `json:"delete,omitempty" jsonschema_description:"DELETE step"`
 }
 
 type GetTablesInput struct {
@@ -80,26 +141,104 @@ type GetFunctionSourceInput struct {
 }
 
 type ExecuteFunctionInput struct {
-	Database string        `json:"database" jsonschema_description:"Database name"`
-	Schema   string        `json:"schema,omitempty" jsonschema_description:"Schema name (PostgreSQL)"`
-	Name     string        `json:"name" jsonschema_description:"Function/procedure name"`
-	Params   []interface{} `json:"params,omitempty" jsonschema_description:"Function parameters"`
+	Database  string        `json:"database" jsonschema_description:"Database name"`
+	Schema    string        `json:"schema,omitempty" jsonschema_description:"Schema name (PostgreSQL)"`
+	Name      string        `json:"name" jsonschema_description:"Function/procedure name"`
+	Params    []interface{} `json:"params,omitempty" jsonschema_description:"Function parameters"`
+	TimeoutMS int           `json:"timeout_ms,omitempty" jsonschema_description:"Query timeout in milliseconds (default QUERY_TIMEOUT_MS, capped at MAX_QUERY_TIMEOUT_MS)"`
+}
+
+type ExplainQueryInput struct {
+	Select    *QuerySelectInput `json:"select,omitempty" jsonschema_description:"Structured SELECT to explain, with the same arguments as query_select"`
+	Database  string            `json:"database,omitempty" jsonschema_description:"Database name, for a raw query"`
+	Query     string            `json:"query,omitempty" jsonschema_description:"Raw SQL statement to explain (requires ALLOW_RAW_QUERY)"`
+	Params    []interface{}     `json:"params,omitempty" jsonschema_description:"Parameters of the raw query"`
+	Analyze   bool              `json:"analyze,omitempty" jsonschema_description:"Execute the statement to report actual rows and timings; any changes are rolled back"`
+	TimeoutMS int               `json:"timeout_ms,omitempty" jsonschema_description:"Query timeout in milliseconds (default QUERY_TIMEOUT_MS, capped at MAX_QUERY_TIMEOUT_MS)"`
 }
 
 // ===== OUTPUT TYPES =====
 
 type QueryOutput struct {
-	Rows     []map[string]interface{} `json:"rows,omitempty" jsonschema_description:"Query result rows"`
-	Affected int64                    `json:"affected,omitempty" jsonschema_description:"Rows affected"`
-	Message  string                   `json:"message,omitempty" jsonschema_description:"Result message"`
+	Rows      []map[string]interface{} `json:"rows,omitempty" jsonschema_description:"Query result rows"`
+	Affected  int64                    `json:"affected,omitempty" jsonschema_description:"Rows affected"`
+	Inserted  int64                    `json:"inserted,omitempty" jsonschema_description:"Rows inserted by an upsert"`
+	Updated   int64                    `json:"updated,omitempty" jsonschema_description:"Existing rows updated by an upsert"`
+	Unchanged int64                    `json:"unchanged,omitempty" jsonschema_description:"Existing rows an upsert without update columns left as they are"`
+	Message   string                   `json:"message,omitempty" jsonschema_description:"Result message"`
+	DryRun    *DryRunOutput            `json:"dry_run,omitempty" jsonschema_description:"Preview returned instead of executing, when dry_run is set"`
+}
+
+type DryRunOutput struct {
+	SQL    string                   `json:"sql" jsonschema_description:"Statement that would be executed"`
+	Args   []interface{}            `json:"args,omitempty" jsonschema_description:"Bound arguments"`
+	Rows   int64                    `json:"rows,omitempty" jsonschema_description:"Rows that would be inserted, updated or deleted"`
+	Before []map[string]interface{} `json:"before,omitempty" jsonschema_description:"Sample of the rows that would change, as they are now"`
+	After  []map[string]interface{} `json:"after,omitempty" jsonschema_description:"The same sample after the change, or the inserted row"`
+
+	Updated      int64 `json:"updated,omitempty" jsonschema_description:"Rows of an upsert that already exist and would be updated"`
+	Unchanged    int64 `json:"unchanged,omitempty" jsonschema_description:"Rows of an upsert that already exist and would be left unchanged, as there are no columns to update"`
+	Batches      int   `json:"batches,omitempty" jsonschema_description:"Number of INSERT statements a bulk insert is split into; sql and args show the first"`
+	ExceedsLimit bool  `json:"exceeds_limit,omitempty" jsonschema_description:"The change would be refused for exceeding the UPDATE/DELETE row limit"`
+}
+
+type ExplainOutput struct {
+	Database string        `json:"database" jsonschema_description:"Database name"`
+	SQL      string        `json:"sql" jsonschema_description:"Statement that was explained"`
+	Args     []interface{} `json:"args,omitempty" jsonschema_description:"Bound arguments"`
+	Analyzed bool          `json:"analyzed,omitempty" jsonschema_description:"The statement was executed and rolled back to collect actual rows and timings"`
+	Plan     interface{}   `json:"plan" jsonschema_description:"Plan tree as reported by the database"`
+	Analysis string        `json:"analysis,omitempty" jsonschema_description:"EXPLAIN ANALYZE output on MySQL, which only reports it as a text tree"`
+	Summary  PlanSummary   `json:"summary" jsonschema_description:"Summary of the plan"`
+}
+
+// PlanSummary condenses a plan into the figures that explain a slow query.
+// Estimates are the planner's and are not reported by SQLite.
+type PlanSummary struct {
+	TotalCost     float64    `json:"total_cost,omitempty" jsonschema_description:"Estimated cost, in the planner's own units"`
+	EstimatedRows int64      `json:"estimated_rows,omitempty" jsonschema_description:"Estimated rows returned"`
+	RowsScanned   int64      `json:"rows_scanned,omitempty" jsonschema_description:"Estimated rows read from tables and indexes"`
+	ExecutionMS   float64    `json:"execution_ms,omitempty" jsonschema_description:"Execution time in milliseconds, when analyzed on PostgreSQL"`
+	SeqScans      []PlanScan `json:"seq_scans" jsonschema_description:"Full table scans"`
+	Hints         []string   `json:"hints" jsonschema_description:"Possible missing indexes and other observations"`
+}
+
+type PlanScan struct {
+	Table      string `json:"table" jsonschema_description:"Scanned table"`
+	Rows       int64  `json:"rows,omitempty" jsonschema_description:"Estimated rows in the table"`
+	ActualRows int64  `json:"actual_rows,omitempty" jsonschema_description:"Rows the scan returned, when analyzed"`
+	Filter     string `json:"filter,omitempty" jsonschema_description:"Condition applied to every scanned row"`
+}
+
+type TransactionOutput struct {
+	Steps   []TransactionStepOutput `json:"steps" jsonschema_description:"Result of each operation, in order"`
+	Message string                  `json:"message,omitempty" jsonschema_description:"Result message"`
+}
+
+type TransactionStepOutput struct {
+	Operation string                   `json:"operation" jsonschema_description:"SELECT, INSERT, UPDATE or DELETE"`
+	Table     string                   `json:"table" jsonschema_description:"Table name"`
+	Rows      []map[string]interface{} `json:"rows,omitempty" jsonschema_description:"Rows returned by a SELECT step, or by a write step with returning"`
+	Affected  int64                    `json:"affected,omitempty" jsonschema_description:"Rows affected by a write step"`
 }
 
 type ListOutput struct {
 	Items []string `json:"items" jsonschema_description:"List of items"`
 }
 
+type TablesOutput struct {
+	Database string   `json:"database" jsonschema_description:"Database name"`
+	Schema   string   `json:"schema,omitempty" jsonschema_description:"Schema name"`
+	Tables   []string `json:"tables" jsonschema_description:"Table names"`
+}
+
 type SchemaOutput struct {
-	Columns []ColumnInfo `json:"columns" jsonschema_description:"Table columns"`
+	Database    string           `json:"database" jsonschema_description:"Database name"`
+	Schema      string           `json:"schema,omitempty" jsonschema_description:"Schema name"`
+	Table       string           `json:"table" jsonschema_description:"Table name"`
+	Columns     []ColumnInfo     `json:"columns" jsonschema_description:"Table columns"`
+	ForeignKeys []ForeignKeyInfo `json:"foreign_keys" jsonschema_description:"Foreign key constraints"`
+	Indexes     []IndexInfo      `json:"indexes" jsonschema_description:"Indexes"`
 }
 
 type ColumnInfo struct {
@@ -108,9 +247,79 @@ type ColumnInfo struct {
 	Nullable   bool   `json:"nullable"`
 	Default    string `json:"default,omitempty"`
 	PrimaryKey bool   `json:"primary_key,omitempty"`
+	Key        string `json:"key,omitempty"`
+	Extra      string `json:"extra,omitempty"`
+}
+
+type ForeignKeyInfo struct {
+	Column        string `json:"column"`
+	ForeignSchema string `json:"foreign_schema"`
+	ForeignTable  string `json:"foreign_table"`
+	ForeignColumn string `json:"foreign_column"`
+}
+
+type IndexInfo struct {
+	Name       string   `json:"name"`
+	Unique     bool     `json:"unique"`
+	Columns    []string `json:"columns,omitempty"`
+	Definition string   `json:"definition,omitempty"`
+}
+
+type SequencesOutput struct {
+	Database  string         `json:"database" jsonschema_description:"Database name"`
+	Schema    string         `json:"schema,omitempty" jsonschema_description:"Schema name"`
+	Sequences []SequenceInfo `json:"sequences" jsonschema_description:"Sequences or auto-increment columns"`
+}
+
+type SequenceInfo struct {
+	Name      string `json:"name"`
+	Table     string `json:"table,omitempty"`
+	Column    string `json:"column,omitempty"`
+	Type      string `json:"type,omitempty"`
+	Start     string `json:"start,omitempty"`
+	Min       string `json:"min,omitempty"`
+	Max       string `json:"max,omitempty"`
+	Increment string `json:"increment,omitempty"`
+	Current   *int64 `json:"current,omitempty"`
+}
+
+type CustomTypesOutput struct {
+	Database string           `json:"database" jsonschema_description:"Database name"`
+	Schema   string           `json:"schema,omitempty" jsonschema_description:"Schema name"`
+	Types    []CustomTypeInfo `json:"types" jsonschema_description:"User-defined types"`
+	Message  string           `json:"message,omitempty" jsonschema_description:"Result message"`
+}
+
+type CustomTypeInfo struct {
+	Name     string   `json:"name"`
+	Category string   `json:"category"`
+	Values   []string `json:"values,omitempty"`
+}
+
+type FunctionsOutput struct {
+	Database  string         `json:"database" jsonschema_description:"Database name"`
+	Schema    string         `json:"schema,omitempty" jsonschema_description:"Schema name"`
+	Functions []FunctionInfo `json:"functions" jsonschema_description:"Functions and procedures"`
+	Message   string         `json:"message,omitempty" jsonschema_description:"Result message"`
+}
+
+type FunctionInfo struct {
+	Name       string `json:"name"`
+	Kind       string `json:"kind"`
+	Arguments  string `json:"arguments,omitempty"`
+	ReturnType string `json:"return_type,omitempty"`
+	Language   string `json:"language,omitempty"`
+	Created    string `json:"created,omitempty"`
+}
+
+type FunctionSourceOutput struct {
+	Schema     string `json:"schema,omitempty" jsonschema_description:"Schema (or database) containing the routine"`
+	Name       string `json:"name" jsonschema_description:"Function/procedure name"`
+	Kind       string `json:"kind,omitempty" jsonschema_description:"function or procedure"`
+	Definition string `json:"definition,omitempty" jsonschema_description:"Source code"`
+	Message    string `json:"message,omitempty" jsonschema_description:"Result message"`
 }
 
 type TextOutput struct {
 	Text string `json:"text" jsonschema_description:"Text output"`
 }
-